@@ -2,6 +2,7 @@ package lsgo
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -17,13 +18,19 @@ type XMLMarshaler interface {
 	MarshalXML(e *xml.Encoder, start *xml.StartElement) error
 }
 
+// XMLAttrMarshaler is XMLMarshaler for types that are also an xml.Marshaler,
+// which can't have a second method named MarshalXML. It is checked first
+type XMLAttrMarshaler interface {
+	MarshalXMLAttrs(e *xml.Encoder, start *xml.StartElement) error
+}
+
 type TranslatedString struct {
 	Version uint16
 	Value   string
 	Handle  string
 }
 
-func (ts TranslatedString) MarshalXML(e *xml.Encoder, start *xml.StartElement) error {
+func (ts TranslatedString) MarshalXMLAttrs(e *xml.Encoder, start *xml.StartElement) error {
 	start.Attr = append(start.Attr,
 		xml.Attr{
 			Name:  xml.Name{Local: "handle"},
@@ -37,6 +44,43 @@ func (ts TranslatedString) MarshalXML(e *xml.Encoder, start *xml.StartElement) e
 	return nil
 }
 
+func (ts TranslatedString) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	ts.MarshalXMLAttrs(e, &start)
+	e.EncodeToken(start)
+	return e.EncodeToken(xml.EndElement{Name: start.Name})
+}
+
+// String returns the handle and version separated by a ";", the value is
+// appended after another ";" if it is set
+func (ts TranslatedString) String() string {
+	s := ts.Handle + ";" + strconv.Itoa(int(ts.Version))
+	if ts.Value != "" {
+		s += ";" + ts.Value
+	}
+	return s
+}
+
+// ParseTranslatedString is the inverse of TranslatedString.String
+func ParseTranslatedString(str string) (TranslatedString, error) {
+	var (
+		ts    TranslatedString
+		parts = strings.SplitN(str, ";", 3)
+	)
+	if len(parts) < 2 {
+		return ts, fmt.Errorf("invalid TranslatedString %q: expected handle;version", str)
+	}
+	ts.Handle = parts[0]
+	v, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		return ts, err
+	}
+	ts.Version = uint16(v)
+	if len(parts) == 3 {
+		ts.Value = parts[2]
+	}
+	return ts, nil
+}
+
 type TranslatedFSStringArgument struct {
 	String TranslatedFSString
 	Key    string
@@ -48,6 +92,20 @@ type TranslatedFSString struct {
 	Arguments []TranslatedFSStringArgument
 }
 
+// String returns tfs encoded as JSON, arguments can be nested arbitrarily deep
+// so the simpler format of TranslatedString is not used
+func (tfs TranslatedFSString) String() string {
+	b, _ := json.Marshal(tfs)
+	return string(b)
+}
+
+// ParseTranslatedFSString is the inverse of TranslatedFSString.String
+func ParseTranslatedFSString(str string) (TranslatedFSString, error) {
+	var tfs TranslatedFSString
+	err := json.Unmarshal([]byte(str), &tfs)
+	return tfs, err
+}
+
 // func (tfs TranslatedFSString) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 // 	start.Attr = append(start.Attr,
 // 		xml.Attr{
@@ -70,17 +128,46 @@ type Ivec []int
 
 func (i Ivec) String() string {
 	b := &strings.Builder{}
-	for _, v := range i {
-		b.WriteString(" ")
+	for n, v := range i {
+		if n > 0 {
+			b.WriteString(" ")
+		}
 		b.WriteString(strconv.Itoa(v))
 	}
-	return b.String()[1:]
+	return b.String()
 }
 
 type Vec []float64
 
+func (v Vec) String() string {
+	b := &strings.Builder{}
+	for n, f := range v {
+		if n > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(strconv.FormatFloat(f, 'f', -1, 32))
+	}
+	return b.String()
+}
+
 type Mat mat.Dense
 
+// String returns the values of the matrix space separated in row-major order
+func (m Mat) String() string {
+	var (
+		M = mat.Dense(m)
+		b = &strings.Builder{}
+	)
+	rows, _ := M.Dims()
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(Vec(M.RawRowView(i)).String())
+	}
+	return b.String()
+}
+
 func (m Mat) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	var (
 		M = mat.Dense(m)
@@ -254,6 +341,34 @@ func (dt DataType) String() string {
 	return ""
 }
 
+// MarshalText encodes dt as its name
+func (dt DataType) MarshalText() ([]byte, error) {
+	if dt < DTNone || dt > DTMax {
+		return nil, fmt.Errorf("unknown data type %d", int(dt))
+	}
+	return []byte(dt.String()), nil
+}
+
+// UnmarshalText decodes a DataType from its name
+func (dt *DataType) UnmarshalText(text []byte) error {
+	v, err := ParseDataType(string(text))
+	if err != nil {
+		return err
+	}
+	*dt = v
+	return nil
+}
+
+// ParseDataType returns the DataType named str, it is the inverse of DataType.String
+func ParseDataType(str string) (DataType, error) {
+	for dt := DTNone; dt <= DTMax; dt++ {
+		if dt.String() == str {
+			return dt, nil
+		}
+	}
+	return DTNone, fmt.Errorf("unknown data type %q", str)
+}
+
 type NodeAttribute struct {
 	Name  string      `xml:"id,attr"`
 	Type  DataType    `xml:"type,attr"`
@@ -269,10 +384,16 @@ func (na NodeAttribute) MarshalXML(e *xml.Encoder, start xml.StartElement) error
 		},
 		t,
 	)
-	v, MarshalXML2 := na.Value.(XMLMarshaler)
 	v1, MarshalXML := na.Value.(xml.Marshaler)
-	if MarshalXML2 {
+	MarshalXML2 := true
+	switch v := na.Value.(type) {
+	case XMLAttrMarshaler:
+		v.MarshalXMLAttrs(e, &start)
+		MarshalXML = false
+	case XMLMarshaler:
 		v.MarshalXML(e, &start)
+	default:
+		MarshalXML2 = false
 	}
 	if !(MarshalXML || MarshalXML2) {
 		start.Attr = append(start.Attr,
@@ -297,6 +418,9 @@ func (na NodeAttribute) MarshalXML(e *xml.Encoder, start xml.StartElement) error
 
 func (na NodeAttribute) String() string {
 	switch na.Type {
+	case DTNone:
+		return ""
+
 	case DTScratchBuffer:
 		// ScratchBuffer is a special case, as its stored as byte[] and ToString() doesn't really do what we want
 		if value, ok := na.Value.([]byte); ok {
@@ -305,18 +429,16 @@ func (na NodeAttribute) String() string {
 		return fmt.Sprint(na.Value)
 
 	case DTDouble:
-		v := na.Value.(float64)
-		if na.Value == 0 {
-			na.Value = 0
+		if v, ok := na.Value.(float64); ok {
+			return strconv.FormatFloat(v, 'f', -1, 64)
 		}
-		return strconv.FormatFloat(v, 'f', -1, 64)
+		return fmt.Sprint(na.Value)
 
 	case DTFloat:
-		v := na.Value.(float32)
-		if na.Value == 0 {
-			na.Value = 0
+		if v, ok := na.Value.(float32); ok {
+			return strconv.FormatFloat(float64(v), 'f', -1, 32)
 		}
-		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		return fmt.Sprint(na.Value)
 
 	default:
 		return fmt.Sprint(na.Value)
@@ -375,12 +497,14 @@ func (na NodeAttribute) IsNumeric() bool {
 	}
 }
 
+// FromString parses str into the value of na, it is the inverse of String.
+// The type of the resulting value is the same as the one produced by the
+// binary readers for na.Type
 func (na *NodeAttribute) FromString(str string) error {
 	if na.IsNumeric() {
 		// Workaround: Some XML files use empty strings, instead of "0" for zero values.
 		if str == "" {
 			str = "0"
-			// Handle hexadecimal integers in XML files
 		}
 	}
 
@@ -389,46 +513,40 @@ func (na *NodeAttribute) FromString(str string) error {
 	switch na.Type {
 	case DTNone:
 		// This is a null type, cannot have a value
+		na.Value = nil
 
 	case DTByte:
-		na.Value = []byte(str)
+		var v uint64
+		v, err = parseUint(str, 8)
+		na.Value = byte(v)
 
 	case DTShort:
-
-		na.Value, err = strconv.ParseInt(str, 0, 16)
-		if err != nil {
-			return err
-		}
+		var v int64
+		v, err = parseInt(str, 16)
+		na.Value = int16(v)
 
 	case DTUShort:
-		na.Value, err = strconv.ParseUint(str, 0, 16)
-		if err != nil {
-			return err
-		}
+		var v uint64
+		v, err = parseUint(str, 16)
+		na.Value = uint16(v)
 
 	case DTInt:
-		na.Value, err = strconv.ParseInt(str, 0, 32)
-		if err != nil {
-			return err
-		}
+		var v int64
+		v, err = parseInt(str, 32)
+		na.Value = int32(v)
 
 	case DTUInt:
-		na.Value, err = strconv.ParseUint(str, 0, 16)
-		if err != nil {
-			return err
-		}
+		var v uint64
+		v, err = parseUint(str, 32)
+		na.Value = uint32(v)
 
 	case DTFloat:
-		na.Value, err = strconv.ParseFloat(str, 32)
-		if err != nil {
-			return err
-		}
+		var v float64
+		v, err = strconv.ParseFloat(str, 32)
+		na.Value = float32(v)
 
 	case DTDouble:
 		na.Value, err = strconv.ParseFloat(str, 64)
-		if err != nil {
-			return err
-		}
 
 	case DTIVec2, DTIVec3, DTIVec4:
 		var (
@@ -436,7 +554,7 @@ func (na *NodeAttribute) FromString(str string) error {
 			length int
 		)
 
-		nums = strings.Split(str, ".")
+		nums = strings.Fields(str)
 		length, err = na.GetColumns()
 		if err != nil {
 			return err
@@ -445,14 +563,14 @@ func (na *NodeAttribute) FromString(str string) error {
 			return fmt.Errorf("a vector of length %d was expected, got %d", length, len(nums))
 		}
 
-		vec := make([]int, length)
+		vec := make(Ivec, length)
 		for i, v := range nums {
 			var n int64
-			n, err = strconv.ParseInt(v, 0, 64)
-			vec[i] = int(n)
+			n, err = parseInt(v, 32)
 			if err != nil {
 				return err
 			}
+			vec[i] = int(n)
 		}
 
 		na.Value = vec
@@ -462,7 +580,7 @@ func (na *NodeAttribute) FromString(str string) error {
 			nums   []string
 			length int
 		)
-		nums = strings.Split(str, ".")
+		nums = strings.Fields(str)
 		length, err = na.GetColumns()
 		if err != nil {
 			return err
@@ -471,9 +589,9 @@ func (na *NodeAttribute) FromString(str string) error {
 			return fmt.Errorf("a vector of length %d was expected, got %d", length, len(nums))
 		}
 
-		vec := make([]float64, length)
+		vec := make(Vec, length)
 		for i, v := range nums {
-			vec[i], err = strconv.ParseFloat(v, 64)
+			vec[i], err = strconv.ParseFloat(v, 32)
 			if err != nil {
 				return err
 			}
@@ -482,71 +600,86 @@ func (na *NodeAttribute) FromString(str string) error {
 		na.Value = vec
 
 	case DTMat2, DTMat3, DTMat3x4, DTMat4x3, DTMat4:
-		// var mat = Matrix.Parse(str);
-		// if (mat.cols != na.GetColumns() || mat.rows != na.GetRows()){
-		//     return errors.New("Invalid column/row count for matrix");
-		// }
-		// value = mat;
-		return errors.New("not implemented")
-
-	case DTBool:
-		na.Value, err = strconv.ParseBool(str)
+		var (
+			nums []string
+			rows int
+			cols int
+		)
+		nums = strings.Fields(str)
+		rows, err = na.GetRows()
+		if err != nil {
+			return err
+		}
+		cols, err = na.GetColumns()
 		if err != nil {
 			return err
 		}
+		if rows*cols != len(nums) {
+			return fmt.Errorf("invalid column/row count for matrix: %d values were expected, got %d", rows*cols, len(nums))
+		}
+
+		vec := make([]float64, rows*cols)
+		for i, v := range nums {
+			vec[i], err = strconv.ParseFloat(v, 32)
+			if err != nil {
+				return err
+			}
+		}
+
+		na.Value = (*Mat)(mat.NewDense(rows, cols, vec))
+
+	case DTBool:
+		na.Value, err = strconv.ParseBool(str)
 
 	case DTString, DTPath, DTFixedString, DTLSString, DTWString, DTLSWString:
 		na.Value = str
 
 	case DTTranslatedString:
-		// // We'll only set the value part of the translated string, not the TranslatedStringKey / Handle part
-		// // That can be changed separately via attribute.Value.Handle
-		// if (value == null)
-		//     value = new TranslatedString();
-
-		// ((TranslatedString)value).Value = str;
+		var v TranslatedString
+		v, err = ParseTranslatedString(str)
+		na.Value = v
 
 	case DTTranslatedFSString:
-		// // We'll only set the value part of the translated string, not the TranslatedStringKey / Handle part
-		// // That can be changed separately via attribute.Value.Handle
-		// if (value == null)
-		//     value = new TranslatedFSString();
-
-		// ((TranslatedFSString)value).Value = str;
+		var v TranslatedFSString
+		v, err = ParseTranslatedFSString(str)
+		na.Value = v
 
 	case DTULongLong:
-		na.Value, err = strconv.ParseUint(str, 10, 64)
-		if err != nil {
-			return err
-		}
+		na.Value, err = parseUint(str, 64)
 
 	case DTScratchBuffer:
 		na.Value, err = base64.StdEncoding.DecodeString(str)
-		if err != nil {
-			return err
-		}
 
 	case DTLong, DTInt64:
-		na.Value, err = strconv.ParseInt(str, 10, 64)
-		if err != nil {
-			return err
-		}
+		na.Value, err = parseInt(str, 64)
 
 	case DTInt8:
-		na.Value, err = strconv.ParseInt(str, 10, 8)
-		if err != nil {
-			return err
-		}
+		var v int64
+		v, err = parseInt(str, 8)
+		na.Value = int8(v)
 
 	case DTUUID:
 		na.Value, err = uuid.Parse(str)
-		if err != nil {
-			return err
-		}
 
 	default:
 		// This should not happen!
 		return fmt.Errorf("not implemented for type %v", na.Type)
 	}
-	return nil
+	return err
+}
+
+// parseInt parses a base 10 integer, hexadecimal integers are accepted with a "0x" prefix
+func parseInt(str string, bitSize int) (int64, error) {
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "-0x") {
+		return strconv.ParseInt(str, 0, bitSize)
+	}
+	return strconv.ParseInt(str, 10, bitSize)
+}
+
+// parseUint parses a base 10 integer, hexadecimal integers are accepted with a "0x" prefix
+func parseUint(str string, bitSize int) (uint64, error) {
+	if strings.HasPrefix(str, "0x") {
+		return strconv.ParseUint(str, 0, bitSize)
+	}
+	return strconv.ParseUint(str, 10, bitSize)
 }
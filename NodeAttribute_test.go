@@ -0,0 +1,340 @@
+package lsgo_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+
+	"github.com/google/uuid"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNodeAttributeRoundTrip(t *testing.T) {
+	values := fixture.Values()
+	for dt := lsgo.DTNone; dt <= lsgo.DTMax; dt++ {
+		v, ok := values[dt]
+		if !ok {
+			t.Errorf("%v: no test value", dt)
+			continue
+		}
+		na := lsgo.NodeAttribute{Name: "a", Type: dt, Value: v}
+		str := na.String()
+
+		got := lsgo.NodeAttribute{Name: "a", Type: dt}
+		if err := got.FromString(str); err != nil {
+			t.Errorf("%v: FromString(%q): %v", dt, str, err)
+			continue
+		}
+		if m, ok := v.(*lsgo.Mat); ok {
+			if g, ok := got.Value.(*lsgo.Mat); !ok || !mat.Equal((*mat.Dense)(m), (*mat.Dense)(g)) {
+				t.Errorf("%v: FromString(%q) = %v, want %v", dt, str, got.Value, v)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got.Value, v) {
+			t.Errorf("%v: FromString(%q) = %#v, want %#v", dt, str, got.Value, v)
+		}
+	}
+}
+
+// TestNodeAttributeRoundTripQuick checks the String and FromString round trip
+// of random values of every DataType
+func TestNodeAttributeRoundTripQuick(t *testing.T) {
+	for dt := lsgo.DTNone; dt <= lsgo.DTMax; dt++ {
+		dt := dt
+		f := func(na lsgo.NodeAttribute) bool {
+			str := na.String()
+			got := lsgo.NodeAttribute{Type: dt}
+			if err := got.FromString(str); err != nil {
+				t.Logf("FromString(%q): %v", str, err)
+				return false
+			}
+			if !sameValue(got.Value, na.Value) {
+				t.Logf("FromString(%q) = %#v, want %#v", str, got.Value, na.Value)
+				return false
+			}
+			return true
+		}
+		c := &quick.Config{
+			Rand: rand.New(rand.NewSource(int64(dt))),
+			Values: func(args []reflect.Value, r *rand.Rand) {
+				args[0] = reflect.ValueOf(lsgo.NodeAttribute{Type: dt, Value: randomValue(dt, r)})
+			},
+		}
+		if err := quick.Check(f, c); err != nil {
+			t.Errorf("%v: %v", dt, err)
+		}
+	}
+}
+
+// specialFloats are chosen for a quarter of the random floats
+var specialFloats = []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0, 0.99999994, -0.1, math.MaxFloat32, math.SmallestNonzeroFloat32}
+
+func randomFloat32(r *rand.Rand) float32 {
+	if r.Intn(4) == 0 {
+		return float32(specialFloats[r.Intn(len(specialFloats))])
+	}
+	return math.Float32frombits(r.Uint32())
+}
+
+func randomFloat64(r *rand.Rand) float64 {
+	if r.Intn(4) == 0 {
+		return specialFloats[r.Intn(len(specialFloats))]
+	}
+	return math.Float64frombits(r.Uint64())
+}
+
+// randomString returns valid UTF-8 with the separators used by String
+func randomString(r *rand.Rand) string {
+	runes := []rune{'a', 'Z', '0', ' ', ';', '\t', '\n', '"', '<', '&', 'é', '☃', '😀'}
+	s := make([]rune, r.Intn(16))
+	for i := range s {
+		s[i] = runes[r.Intn(len(runes))]
+	}
+	return string(s)
+}
+
+// randomHandle returns a handle, which can't contain a ";"
+func randomHandle(r *rand.Rand) string {
+	const chars = "abcdefg0123456789"
+	b := make([]byte, 1+r.Intn(37))
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return "h" + string(b)
+}
+
+func randomTranslatedFSString(r *rand.Rand, depth int) lsgo.TranslatedFSString {
+	tfs := lsgo.TranslatedFSString{TranslatedString: lsgo.TranslatedString{
+		Version: uint16(r.Intn(math.MaxUint16 + 1)),
+		Value:   randomString(r),
+		Handle:  randomHandle(r),
+	}}
+	if depth > 0 {
+		for i := r.Intn(3); i > 0; i-- {
+			tfs.Arguments = append(tfs.Arguments, lsgo.TranslatedFSStringArgument{
+				String: randomTranslatedFSString(r, depth-1),
+				Key:    randomString(r),
+				Value:  randomString(r),
+			})
+		}
+	}
+	return tfs
+}
+
+// randomValue returns a random value of dt of the type produced by the binary readers
+func randomValue(dt lsgo.DataType, r *rand.Rand) interface{} {
+	switch dt {
+	case lsgo.DTByte:
+		return byte(r.Uint32())
+	case lsgo.DTShort:
+		return int16(r.Uint32())
+	case lsgo.DTUShort:
+		return uint16(r.Uint32())
+	case lsgo.DTInt:
+		return int32(r.Uint32())
+	case lsgo.DTUInt:
+		return r.Uint32()
+	case lsgo.DTFloat:
+		return randomFloat32(r)
+	case lsgo.DTDouble:
+		return randomFloat64(r)
+	case lsgo.DTIVec2, lsgo.DTIVec3, lsgo.DTIVec4:
+		n, _ := dt.GetColumns()
+		v := make(lsgo.Ivec, n)
+		for i := range v {
+			v[i] = int(int32(r.Uint32()))
+		}
+		return v
+	case lsgo.DTVec2, lsgo.DTVec3, lsgo.DTVec4:
+		n, _ := dt.GetColumns()
+		v := make(lsgo.Vec, n)
+		for i := range v {
+			v[i] = float64(randomFloat32(r))
+		}
+		return v
+	case lsgo.DTMat2, lsgo.DTMat3, lsgo.DTMat3x4, lsgo.DTMat4x3, lsgo.DTMat4:
+		rows, _ := dt.GetRows()
+		cols, _ := dt.GetColumns()
+		data := make([]float64, rows*cols)
+		for i := range data {
+			data[i] = float64(randomFloat32(r))
+		}
+		return (*lsgo.Mat)(mat.NewDense(rows, cols, data))
+	case lsgo.DTBool:
+		return r.Intn(2) == 1
+	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString, lsgo.DTWString, lsgo.DTLSWString:
+		return randomString(r)
+	case lsgo.DTULongLong:
+		return r.Uint64()
+	case lsgo.DTScratchBuffer:
+		b := make([]byte, r.Intn(32))
+		r.Read(b)
+		return b
+	case lsgo.DTLong, lsgo.DTInt64:
+		return int64(r.Uint64())
+	case lsgo.DTInt8:
+		return int8(r.Uint32())
+	case lsgo.DTTranslatedString:
+		return lsgo.TranslatedString{
+			Version: uint16(r.Uint32()),
+			Value:   randomString(r),
+			Handle:  randomHandle(r),
+		}
+	case lsgo.DTUUID:
+		var u uuid.UUID
+		r.Read(u[:])
+		return u
+	case lsgo.DTTranslatedFSString:
+		return randomTranslatedFSString(r, 2)
+	default:
+		return nil
+	}
+}
+
+// sameFloat reports whether a and b are the same float, NaN is the same as
+// NaN and 0 is not the same as -0
+func sameFloat(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b && math.Signbit(a) == math.Signbit(b)
+}
+
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameFloat(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue is reflect.DeepEqual with floats compared by sameFloat
+func sameValue(a, b interface{}) bool {
+	switch a := a.(type) {
+	case float32:
+		b, ok := b.(float32)
+		return ok && sameFloat(float64(a), float64(b))
+	case float64:
+		b, ok := b.(float64)
+		return ok && sameFloat(a, b)
+	case lsgo.Vec:
+		b, ok := b.(lsgo.Vec)
+		return ok && sameFloats(a, b)
+	case *lsgo.Mat:
+		b, ok := b.(*lsgo.Mat)
+		if !ok {
+			return false
+		}
+		ma, mb := (*mat.Dense)(a), (*mat.Dense)(b)
+		ar, ac := ma.Dims()
+		br, bc := mb.Dims()
+		return ar == br && ac == bc && sameFloats(ma.RawMatrix().Data, mb.RawMatrix().Data)
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func TestNodeAttributeFromString(t *testing.T) {
+	tests := []struct {
+		dt      lsgo.DataType
+		str     string
+		want    interface{}
+		wantErr bool
+	}{
+		{dt: lsgo.DTByte, str: "0xff", want: byte(255)},
+		{dt: lsgo.DTShort, str: "-0x8000", want: int16(-32768)},
+		{dt: lsgo.DTUShort, str: "0xFFFF", want: uint16(65535)},
+		{dt: lsgo.DTInt, str: "0x7fffffff", want: int32(math.MaxInt32)},
+		{dt: lsgo.DTUInt, str: "0xffffffff", want: uint32(math.MaxUint32)},
+		{dt: lsgo.DTULongLong, str: "0xffffffffffffffff", want: uint64(math.MaxUint64)},
+		{dt: lsgo.DTInt64, str: "-0x10", want: int64(-16)},
+		{dt: lsgo.DTInt8, str: "0x7f", want: int8(127)},
+		{dt: lsgo.DTIVec3, str: "0x10 -0x1 3", want: lsgo.Ivec{16, -1, 3}},
+		{dt: lsgo.DTInt, str: "010", want: int32(10)},
+		{dt: lsgo.DTInt, str: "", want: int32(0)},
+		{dt: lsgo.DTByte, str: "0x100", wantErr: true},
+		{dt: lsgo.DTInt, str: "1.5", wantErr: true},
+
+		{dt: lsgo.DTFloat, str: "1e-3", want: float32(0.001)},
+		{dt: lsgo.DTFloat, str: "", want: float32(0)},
+		{dt: lsgo.DTDouble, str: "-2.5", want: -2.5},
+		{dt: lsgo.DTVec3, str: "1 2.5 -3", want: lsgo.Vec{1, 2.5, -3}},
+		{dt: lsgo.DTVec2, str: "1 2 3", wantErr: true},
+		{dt: lsgo.DTMat2, str: "1 2 3", wantErr: true},
+
+		{dt: lsgo.DTBool, str: "True", want: true},
+		{dt: lsgo.DTBool, str: "false", want: false},
+
+		{dt: lsgo.DTTranslatedString, str: "h1;3", want: lsgo.TranslatedString{Version: 3, Handle: "h1"}},
+		{dt: lsgo.DTTranslatedString, str: "h1;3;text;with;semicolons", want: lsgo.TranslatedString{Version: 3, Value: "text;with;semicolons", Handle: "h1"}},
+		{dt: lsgo.DTTranslatedString, str: "h1", wantErr: true},
+		{dt: lsgo.DTTranslatedString, str: "h1;x", wantErr: true},
+	}
+	for _, tt := range tests {
+		na := lsgo.NodeAttribute{Type: tt.dt}
+		err := na.FromString(tt.str)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: FromString(%q) = %#v, want an error", tt.dt, tt.str, na.Value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: FromString(%q): %v", tt.dt, tt.str, err)
+			continue
+		}
+		if !reflect.DeepEqual(na.Value, tt.want) {
+			t.Errorf("%v: FromString(%q) = %#v, want %#v", tt.dt, tt.str, na.Value, tt.want)
+		}
+	}
+}
+
+func TestNodeAttributeStringMatrix(t *testing.T) {
+	na := lsgo.NodeAttribute{Type: lsgo.DTMat3x4, Value: fixture.Values()[lsgo.DTMat3x4]}
+	want := "1 2 3 4 5 6 7 8 9 10 11 0.1"
+	if got := na.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseDataType(t *testing.T) {
+	for dt := lsgo.DTNone; dt <= lsgo.DTMax; dt++ {
+		got, err := lsgo.ParseDataType(dt.String())
+		if err != nil {
+			t.Errorf("ParseDataType(%q): %v", dt.String(), err)
+			continue
+		}
+		if got != dt {
+			t.Errorf("ParseDataType(%q) = %d, want %d", dt.String(), got, dt)
+		}
+
+		text, err := dt.MarshalText()
+		if err != nil {
+			t.Errorf("%v: MarshalText: %v", dt, err)
+			continue
+		}
+		var u lsgo.DataType
+		if err = u.UnmarshalText(text); err != nil || u != dt {
+			t.Errorf("UnmarshalText(%q) = %d, %v, want %d", text, u, err, dt)
+		}
+	}
+
+	for _, str := range []string{"", "int", "Int32", "unknown"} {
+		if dt, err := lsgo.ParseDataType(str); err == nil {
+			t.Errorf("ParseDataType(%q) = %v, want an error", str, dt)
+		}
+	}
+	if _, err := lsgo.DataType(lsgo.DTMax + 1).MarshalText(); err == nil {
+		t.Errorf("MarshalText of %d did not fail", lsgo.DTMax+1)
+	}
+}
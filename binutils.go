@@ -8,9 +8,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"reflect"
 
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
 	"github.com/pierrec/lz4/v4"
 	"gonum.org/v1/gonum/mat"
 )
@@ -34,6 +37,141 @@ func clen(n []byte) int {
 	return len(n)
 }
 
+// A SliceReader reads from an in-memory byte slice. The Read functions in
+// this package return sub-slices of its buffer instead of copying when they
+// are given a *SliceReader
+type SliceReader struct {
+	b   []byte
+	off int
+}
+
+// NewSliceReader returns a SliceReader reading from b
+func NewSliceReader(b []byte) *SliceReader {
+	return &SliceReader{b: b}
+}
+
+// Len returns the number of unread bytes
+func (r *SliceReader) Len() int {
+	return len(r.b) - r.off
+}
+
+// Bytes returns the whole buffer of r
+func (r *SliceReader) Bytes() []byte {
+	return r.b
+}
+
+// Next returns the next n bytes and advances past them. The returned slice
+// is only valid as long as the buffer of r is not modified.
+// It returns io.EOF if there is no data left and io.ErrUnexpectedEOF if
+// there are less than n bytes left, the same as io.ReadFull
+func (r *SliceReader) Next(n int) ([]byte, error) {
+	if r.off >= len(r.b) && n > 0 {
+		return nil, io.EOF
+	}
+	if n < 0 || n > len(r.b)-r.off {
+		r.off = len(r.b)
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := r.b[r.off : r.off+n]
+	r.off += n
+	return p, nil
+}
+
+func (r *SliceReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.b) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, r.b[r.off:])
+	r.off += n
+	return n, nil
+}
+
+func (r *SliceReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = int64(r.off) + offset
+	case io.SeekEnd:
+		abs = int64(len(r.b)) + offset
+	default:
+		return 0, errors.New("lsgo.SliceReader.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("lsgo.SliceReader.Seek: negative position")
+	}
+	if abs > int64(len(r.b)) {
+		abs = int64(len(r.b))
+	}
+	r.off = int(abs)
+	return abs, nil
+}
+
+// ReadBytes reads the next n bytes of r. If r is a *SliceReader the bytes
+// are not copied, the returned slice is part of its buffer
+func ReadBytes(r io.Reader, n int) ([]byte, error) {
+	if sr, ok := r.(*SliceReader); ok {
+		return sr.Next(n)
+	}
+	p := make([]byte, n)
+	_, err := io.ReadFull(r, p)
+	return p, err
+}
+
+// ReadAll reads the rest of r. If r is a *SliceReader the bytes are not
+// copied, the returned slice is part of its buffer
+func ReadAll(r io.Reader) ([]byte, error) {
+	if sr, ok := r.(*SliceReader); ok {
+		return sr.Next(sr.Len())
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ReadUint8 reads a single byte from r
+func ReadUint8(r io.Reader) (uint8, error) {
+	p, err := ReadBytes(r, 1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// ReadUint16 reads a uint16 in the given byte order from r
+func ReadUint16(r io.Reader, order binary.ByteOrder) (uint16, error) {
+	p, err := ReadBytes(r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint16(p), nil
+}
+
+// ReadUint32 reads a uint32 in the given byte order from r
+func ReadUint32(r io.Reader, order binary.ByteOrder) (uint32, error) {
+	p, err := ReadBytes(r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint32(p), nil
+}
+
+func readInt32(r io.Reader, order binary.ByteOrder) (int32, error) {
+	v, err := ReadUint32(r, order)
+	return int32(v), err
+}
+
+// ReadUint64 reads a uint64 in the given byte order from r
+func ReadUint64(r io.Reader, order binary.ByteOrder) (uint64, error) {
+	p, err := ReadBytes(r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint64(p), nil
+}
+
 func CompressionFlagsToMethod(flags byte) CompressionMethod {
 	switch CompressionMethod(flags & 0x0f) {
 	case CMNone:
@@ -45,6 +183,9 @@ func CompressionFlagsToMethod(flags byte) CompressionMethod {
 	case CMLZ4:
 		return CMLZ4
 
+	case CMZstd:
+		return CMZstd
+
 	default:
 		return CMInvalid
 	}
@@ -77,52 +218,281 @@ func MakeCompressionFlags(method CompressionMethod, level CompressionLevel) int
 		flags = 0x1
 	} else if method == CMLZ4 {
 		flags = 0x2
+	} else if method == CMZstd {
+		flags = 0x3
 	}
 
 	return flags | int(level)
 }
 
-func Decompress(compressed io.Reader, uncompressedSize int, compressionFlags byte, chunked bool) io.ReadSeeker {
+// Decompress returns a reader of the uncompressedSize bytes compressed in
+// compressed. The data is decompressed as it is read, except for LZ4 blocks
+// which can only be decompressed whole and are returned as an io.ReadSeeker.
+// The reader reports io.ErrUnexpectedEOF if there is less data than
+// uncompressedSize, ErrDecompressedSize if there is more, and the error of
+// the decompressor if the data or its checksum is invalid
+func Decompress(compressed io.Reader, uncompressedSize int, compressionFlags byte, chunked bool) (io.Reader, error) {
+	er := &exactReader{left: int64(uncompressedSize), size: uncompressedSize}
+	switch CompressionMethod(compressionFlags & 0x0f) {
+	case CMNone:
+		er.r = compressed
+
+	case CMZlib:
+		zr, err := zlib.NewReader(compressed)
+		if err != nil {
+			return nil, err
+		}
+		er.r = zr
+
+	case CMLZ4:
+		if !chunked {
+			src, err := ioutil.ReadAll(compressed)
+			if err != nil {
+				return nil, err
+			}
+			dst, err := DecompressBytes(src, uncompressedSize, compressionFlags, chunked)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(dst), nil
+		}
+		er.r = lz4.NewReader(compressed)
+
+	case CMZstd:
+		zr, err := zstd.NewReader(compressed, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		er.r = zr
+		er.done = zr.Close
+
+	default:
+		return nil, fmt.Errorf("no decompressor found for this format: %v", compressionFlags)
+	}
+	return er, nil
+}
+
+// exactReader reads exactly size bytes from r, the output of a decompressor
+type exactReader struct {
+	r    io.Reader
+	left int64
+	size int
+
+	// done is called once r is no longer needed
+	done func()
+	err  error
+}
+
+func (er *exactReader) Read(p []byte) (int, error) {
+	if er.err != nil {
+		return 0, er.err
+	}
+	if er.left == 0 {
+		// Reading to the end of the stream checks that there is no more data
+		// and lets the decompressor verify its checksum
+		var b [1]byte
+		n, err := io.ReadFull(er.r, b[:])
+		if n > 0 {
+			err = fmt.Errorf("%w: more than %d bytes", ErrDecompressedSize, er.size)
+		}
+		er.fail(err)
+		return 0, err
+	}
+
+	if int64(len(p)) > er.left {
+		p = p[:er.left]
+	}
+	n, err := er.r.Read(p)
+	er.left -= int64(n)
+	if err == io.EOF {
+		if er.left == 0 {
+			// Checked by the next Read
+			return n, nil
+		}
+		err = fmt.Errorf("%w: %d of %d bytes", io.ErrUnexpectedEOF, int64(er.size)-er.left, er.size)
+	}
+	if err != nil {
+		er.fail(err)
+	}
+	return n, err
+}
+
+func (er *exactReader) fail(err error) {
+	er.err = err
+	if er.done != nil {
+		er.done()
+		er.done = nil
+	}
+}
+
+// DecompressBytes decompresses src into a new buffer of exactly uncompressedSize bytes
+func DecompressBytes(src []byte, uncompressedSize int, compressionFlags byte, chunked bool) ([]byte, error) {
 	switch CompressionMethod(compressionFlags & 0x0f) {
 	case CMNone:
-		if v, ok := compressed.(io.ReadSeeker); ok {
-			return v
+		if len(src) != uncompressedSize {
+			return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrDecompressedSize, len(src), uncompressedSize)
+		}
+		return src, nil
+
+	case CMLZ4:
+		if chunked {
+			break
+		}
+		dst := make([]byte, uncompressedSize)
+		n, err := lz4.UncompressBlock(src, dst)
+		if err != nil {
+			return nil, err
+		}
+		if n != uncompressedSize {
+			return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrDecompressedSize, n, uncompressedSize)
 		}
-		panic(errors.New("compressed must be an io.ReadSeeker if there is no compression"))
+		return dst, nil
+	}
+
+	r, err := Decompress(bytes.NewReader(src), uncompressedSize, compressionFlags, chunked)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, uncompressedSize)
+	_, err = io.ReadFull(r, dst)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Read(dst[:0])
+	if err != io.EOF {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// Compress writes data compressed with method to w, it is the inverse of
+// Decompress. Chunked selects the LZ4 frame format used for every section
+// except the names of files since VerChunkedCompress, otherwise LZ4 data is
+// written as a single raw block. Chunked is ignored for zlib. Only the
+// zstd decoder is vendored, zstd data can be decompressed but not written
+func Compress(w io.Writer, data []byte, method CompressionMethod, level CompressionLevel, chunked bool) error {
+	switch method {
+	case CMNone:
+		_, err := w.Write(data)
+		return err
 
 	case CMZlib:
-		zr, _ := zlib.NewReader(compressed)
-		v, _ := ioutil.ReadAll(zr)
-		return bytes.NewReader(v)
+		zlevel := zlib.DefaultCompression
+		switch level {
+		case FastCompression:
+			zlevel = zlib.BestSpeed
+		case DefaultCompression:
+			zlevel = zlib.DefaultCompression
+		case MaxCompression:
+			zlevel = zlib.BestCompression
+		default:
+			return fmt.Errorf("invalid compression level %v", level)
+		}
+		zw, err := zlib.NewWriterLevel(w, zlevel)
+		if err != nil {
+			return err
+		}
+		_, err = zw.Write(data)
+		if err != nil {
+			return err
+		}
+		return zw.Close()
 
 	case CMLZ4:
+		if level != FastCompression && level != DefaultCompression && level != MaxCompression {
+			return fmt.Errorf("invalid compression level %v", level)
+		}
 		if chunked {
-			zr := lz4.NewReader(compressed)
-			p := make([]byte, uncompressedSize)
-			_, err := zr.Read(p)
+			zlevel := lz4.Fast
+			if level == MaxCompression {
+				zlevel = lz4.Level9
+			}
+			zw := lz4.NewWriter(w)
+			err := zw.Apply(lz4.BlockSizeOption(lz4.Block64Kb), lz4.ChecksumOption(true), lz4.CompressionLevelOption(zlevel))
+			if err != nil {
+				return err
+			}
+			_, err = zw.Write(data)
 			if err != nil {
-				panic(err)
+				return err
 			}
-			return bytes.NewReader(p)
+			return zw.Close()
+		}
+
+		var (
+			dst = make([]byte, lz4.CompressBlockBound(len(data)))
+			n   int
+			err error
+		)
+		if level == MaxCompression {
+			n, err = lz4.CompressBlockHC(data, dst, lz4.Level9, nil, nil)
+		} else {
+			n, err = lz4.CompressBlock(data, dst, nil)
 		}
-		src, _ := ioutil.ReadAll(compressed)
-		dst := make([]byte, uncompressedSize*2)
-		_, err := lz4.UncompressBlock(src, dst)
 		if err != nil {
-			panic(err)
+			return err
 		}
-
-		return bytes.NewReader(dst)
+		_, err = w.Write(dst[:n])
+		return err
 
 	default:
-		panic(fmt.Errorf("no decompressor found for this format: %v", compressionFlags))
+		return fmt.Errorf("no compressor found for this method: %v", method)
+	}
+}
+
+// swapPairs swaps every pair of bytes in nums
+func swapPairs(nums []byte) {
+	for i := 0; i+1 < len(nums); i += 2 {
+		nums[i], nums[i+1] = nums[i+1], nums[i]
 	}
 }
 
+// UUIDFromBytes converts the serialized bytes of a DTUUID attribute to a uuid.UUID
+func UUIDFromBytes(p []byte, mode GUIDMode) (uuid.UUID, error) {
+	return uuidFromBytes(p, binary.LittleEndian, mode)
+}
+
+func uuidFromBytes(p []byte, endianness binary.ByteOrder, mode GUIDMode) (uuid.UUID, error) {
+	var v uuid.UUID
+	if len(p) != len(v) {
+		return v, fmt.Errorf("invalid UUID length %d", len(p))
+	}
+	copy(v[:], p)
+	// The first three groups are integers, uuid.UUID stores them big-endian
+	if endianness != binary.BigEndian {
+		reverse(v[:4])
+		reverse(v[4:6])
+		reverse(v[6:8])
+	}
+	if mode == GUIDByteSwapped {
+		swapPairs(v[8:])
+	}
+	return v, nil
+}
+
+// UUIDToBytes converts v to the serialized bytes of a DTUUID attribute, it is the inverse of UUIDFromBytes
+func UUIDToBytes(v uuid.UUID, mode GUIDMode) []byte {
+	p := make([]byte, len(v))
+	copy(p, v[:])
+	reverse(p[:4])
+	reverse(p[4:6])
+	reverse(p[6:8])
+	if mode == GUIDByteSwapped {
+		swapPairs(p[8:])
+	}
+	return p
+}
+
+// ConvertUUID converts v that was decoded using the GUIDMode from to the GUIDMode to
+func ConvertUUID(v uuid.UUID, from, to GUIDMode) uuid.UUID {
+	if from != to {
+		swapPairs(v[8:])
+	}
+	return v
+}
+
 func ReadCString(r io.Reader, length int) (string, error) {
-	var err error
-	buf := make([]byte, length)
-	_, err = r.Read(buf)
+	buf, err := ReadBytes(r, length)
 	if err != nil {
 		return string(buf[:clen(buf)]), err
 	}
@@ -130,88 +500,102 @@ func ReadCString(r io.Reader, length int) (string, error) {
 	return string(buf[:clen(buf)]), nil
 }
 
-func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, l log.Logger) (NodeAttribute, error) {
+func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, endianness binary.ByteOrder, guidMode GUIDMode, l log.Logger) (NodeAttribute, error) {
 	var (
 		attr = NodeAttribute{
 			Type: DT,
 			Name: name,
 		}
 		err error
+		p   []byte
 
 		pos int64
-		n   int
 	)
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	switch DT {
 	case DTNone:
 
-		l.Log("member", name, "read", length, "start position", pos, "value", nil)
+		l.Log("member", name, "read", 0, "start position", pos, "value", nil)
 
 		return attr, nil
 
 	case DTByte:
-		p := make([]byte, 1)
-		n, err = r.Read(p)
+		p, err = ReadBytes(r, 1)
+		if err != nil {
+			return attr, err
+		}
 		attr.Value = p[0]
 
-		l.Log("member", name, "read", n, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTShort:
-		var v int16
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 2)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = int16(endianness.Uint16(p))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTUShort:
-		var v uint16
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 2)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = endianness.Uint16(p)
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTInt:
-		var v int32
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 4)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = int32(endianness.Uint32(p))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTUInt:
-		var v uint32
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 4)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = endianness.Uint32(p)
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTFloat:
-		var v float32
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 4)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = math.Float32frombits(endianness.Uint32(p))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTDouble:
-		var v float64
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 8)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = math.Float64frombits(endianness.Uint64(p))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTIVec2, DTIVec3, DTIVec4:
 		var col int
@@ -219,18 +603,17 @@ func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, l log
 		if err != nil {
 			return attr, err
 		}
+		p, err = ReadBytes(r, col*4)
+		if err != nil {
+			return attr, err
+		}
 		vec := make(Ivec, col)
 		for i := range vec {
-			var v int32
-			err = binary.Read(r, binary.LittleEndian, &v)
-			if err != nil {
-				return attr, err
-			}
-			vec[i] = int(v)
+			vec[i] = int(int32(endianness.Uint32(p[i*4:])))
 		}
 		attr.Value = vec
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
 		return attr, nil
 
@@ -240,18 +623,17 @@ func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, l log
 		if err != nil {
 			return attr, err
 		}
+		p, err = ReadBytes(r, col*4)
+		if err != nil {
+			return attr, err
+		}
 		vec := make(Vec, col)
 		for i := range vec {
-			var v float32
-			err = binary.Read(r, binary.LittleEndian, &v)
-			if err != nil {
-				return attr, err
-			}
-			vec[i] = float64(v)
+			vec[i] = float64(math.Float32frombits(endianness.Uint32(p[i*4:])))
 		}
 		attr.Value = vec
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
 		return attr, nil
 
@@ -268,74 +650,78 @@ func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, l log
 		if err != nil {
 			return attr, err
 		}
+		p, err = ReadBytes(r, col*row*4)
+		if err != nil {
+			return attr, err
+		}
 		vec := make(Vec, col*row)
 
+		// Matrices are stored column-major
 		for c := 0; c < col; c++ {
 			for ro := 0; ro < row; ro++ {
-				var v float32
-				err = binary.Read(r, binary.LittleEndian, &v)
-				if err != nil {
-					return attr, err
-				}
-				vec[ro*col+c] = float64(v)
+				vec[ro*col+c] = float64(math.Float32frombits(endianness.Uint32(p[(c*row+ro)*4:])))
 			}
 		}
 		attr.Value = (*Mat)(mat.NewDense(row, col, []float64(vec)))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
 		return attr, nil
 
 	case DTBool:
-		var v bool
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 1)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = p[0] != 0
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTULongLong:
-		var v uint64
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 8)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = endianness.Uint64(p)
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTLong, DTInt64:
-		var v int64
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 8)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = int64(endianness.Uint64(p))
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTInt8:
-		var v int8
-		err = binary.Read(r, binary.LittleEndian, &v)
-		attr.Value = v
+		p, err = ReadBytes(r, 1)
+		if err != nil {
+			return attr, err
+		}
+		attr.Value = int8(p[0])
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
-		return attr, err
+		return attr, nil
 
 	case DTUUID:
 		var v uuid.UUID
-		p := make([]byte, 16)
-		n, err = r.Read(p)
+		p, err = ReadBytes(r, 16)
 		if err != nil {
 			return attr, err
 		}
-		reverse(p[:4])
-		reverse(p[4:6])
-		reverse(p[6:8])
-		v, err = uuid.FromBytes(p)
+		v, err = uuidFromBytes(p, endianness, guidMode)
 		attr.Value = v
 
-		l.Log("member", name, "read", n, "start position", pos, "value", attr.Value)
+		l.Log("member", name, "read", len(p), "start position", pos, "value", attr.Value)
 
 		return attr, err
 
@@ -345,63 +731,9 @@ func ReadAttribute(r io.ReadSeeker, name string, DT DataType, length uint, l log
 		// pretty.Log(attr)
 		return attr, fmt.Errorf("readAttribute() not implemented for type %v", DT)
 	}
-
-	// return attr, nil
 }
 
-// LimitReadSeeker returns a Reader that reads from r
-// but stops with EOF after n bytes.
-// The underlying implementation is a *LimitedReader.
-func LimitReadSeeker(r io.ReadSeeker, n int64) io.ReadSeeker { return &LimitedReadSeeker{r, n} }
-
-// A LimitedReadSeeker reads from R but limits the amount of
-// data returned to just N bytes. Each call to Read
-// updates N to reflect the new amount remaining.
-// Read returns EOF when N <= 0 or when the underlying R returns EOF.
-type LimitedReadSeeker struct {
-	R io.ReadSeeker // underlying reader
-	N int64         // max bytes remaining
-}
-
-func (l *LimitedReadSeeker) Read(p []byte) (n int, err error) {
-	if l.N <= 0 {
-		return 0, io.EOF
-	}
-	if int64(len(p)) > l.N {
-		p = p[0:l.N]
-	}
-	n, err = l.R.Read(p)
-	l.N -= int64(n)
-	return
-}
-
-func (l *LimitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
-	switch whence {
-	case io.SeekStart:
-		n, err := l.R.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return n, err
-		}
-		l.N += n - offset
-		return l.R.Seek(offset, whence)
-
-	case io.SeekEnd:
-		n, err := l.R.Seek(l.N, io.SeekCurrent)
-		if err != nil {
-			return n, err
-		}
-		l.N = 0 - offset
-		return l.R.Seek(offset, io.SeekCurrent)
-
-	case io.SeekCurrent:
-		l.N -= offset
-		return l.R.Seek(offset, whence)
-	default:
-		return -1, io.ErrNoProgress
-	}
-}
-
-func ReadTranslatedString(r io.ReadSeeker, version FileVersion, engineVersion uint32) (TranslatedString, error) {
+func ReadTranslatedString(r io.ReadSeeker, endianness binary.ByteOrder, version FileVersion, engineVersion uint32) (TranslatedString, error) {
 	var (
 		str TranslatedString
 		err error
@@ -409,12 +741,12 @@ func ReadTranslatedString(r io.ReadSeeker, version FileVersion, engineVersion ui
 
 	if version >= VerBG3 || engineVersion == 0x4000001d {
 		var version uint16
-		err = binary.Read(r, binary.LittleEndian, &version)
+		version, err = ReadUint16(r, endianness)
 		if err != nil {
 			return str, err
 		}
 		str.Version = version
-		err = binary.Read(r, binary.LittleEndian, &version)
+		version, err = ReadUint16(r, endianness)
 		if err != nil {
 			return str, err
 		}
@@ -430,26 +762,20 @@ func ReadTranslatedString(r io.ReadSeeker, version FileVersion, engineVersion ui
 	} else {
 		str.Version = 0
 
-		var (
-			vlength int32
-			v       []byte
-			// n       int
-		)
+		var vlength int32
 
-		err = binary.Read(r, binary.LittleEndian, &vlength)
+		vlength, err = readInt32(r, endianness)
 		if err != nil {
 			return str, err
 		}
-		v = make([]byte, vlength)
-		_, err = r.Read(v)
+		str.Value, err = ReadCString(r, int(vlength))
 		if err != nil {
 			return str, err
 		}
-		str.Value = string(v)
 	}
 
 	var handleLength int32
-	err = binary.Read(r, binary.LittleEndian, &handleLength)
+	handleLength, err = readInt32(r, endianness)
 	if err != nil {
 		return str, err
 	}
@@ -460,7 +786,7 @@ func ReadTranslatedString(r io.ReadSeeker, version FileVersion, engineVersion ui
 	return str, nil
 }
 
-func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSString, error) {
+func ReadTranslatedFSString(r io.Reader, endianness binary.ByteOrder, version FileVersion) (TranslatedFSString, error) {
 	var (
 		str = TranslatedFSString{}
 		err error
@@ -468,7 +794,7 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 
 	if version >= VerBG3 {
 		var version uint16
-		err = binary.Read(r, binary.LittleEndian, &version)
+		version, err = ReadUint16(r, endianness)
 		if err != nil {
 			return str, err
 		}
@@ -478,7 +804,7 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 
 		var length int32
 
-		err = binary.Read(r, binary.LittleEndian, &length)
+		length, err = readInt32(r, endianness)
 		if err != nil {
 			return str, err
 		}
@@ -489,7 +815,7 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 	}
 
 	var handleLength int32
-	err = binary.Read(r, binary.LittleEndian, &handleLength)
+	handleLength, err = readInt32(r, endianness)
 	if err != nil {
 		return str, err
 	}
@@ -499,7 +825,7 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 	}
 
 	var arguments int32
-	err = binary.Read(r, binary.LittleEndian, &arguments)
+	arguments, err = readInt32(r, endianness)
 	if err != nil {
 		return str, err
 	}
@@ -508,7 +834,7 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 		arg := TranslatedFSStringArgument{}
 
 		var argKeyLength int32
-		err = binary.Read(r, binary.LittleEndian, &argKeyLength)
+		argKeyLength, err = readInt32(r, endianness)
 		if err != nil {
 			return str, err
 		}
@@ -517,13 +843,13 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 			return str, err
 		}
 
-		arg.String, err = ReadTranslatedFSString(r, version)
+		arg.String, err = ReadTranslatedFSString(r, endianness, version)
 		if err != nil {
 			return str, err
 		}
 
 		var argValueLength int32
-		err = binary.Read(r, binary.LittleEndian, &argValueLength)
+		argValueLength, err = readInt32(r, endianness)
 		if err != nil {
 			return str, err
 		}
@@ -537,3 +863,232 @@ func ReadTranslatedFSString(r io.Reader, version FileVersion) (TranslatedFSStrin
 
 	return str, nil
 }
+
+// WriteCString writes s followed by a null terminator
+func WriteCString(w io.Writer, s string) error {
+	_, err := io.WriteString(w, s+"\x00")
+	return err
+}
+
+// WriteAttribute writes the value of attr, it is the inverse of ReadAttribute
+func WriteAttribute(w io.Writer, attr NodeAttribute, endianness binary.ByteOrder, guidMode GUIDMode) error {
+	var (
+		v   interface{}
+		err error
+	)
+	switch attr.Type {
+	case DTNone:
+		return nil
+
+	case DTByte:
+		v, err = attrValue(attr, byte(0))
+
+	case DTShort:
+		v, err = attrValue(attr, int16(0))
+
+	case DTUShort:
+		v, err = attrValue(attr, uint16(0))
+
+	case DTInt:
+		v, err = attrValue(attr, int32(0))
+
+	case DTUInt:
+		v, err = attrValue(attr, uint32(0))
+
+	case DTFloat:
+		v, err = attrValue(attr, float32(0))
+
+	case DTDouble:
+		v, err = attrValue(attr, float64(0))
+
+	case DTIVec2, DTIVec3, DTIVec4:
+		var vec Ivec
+		vec, err = ivecValue(attr)
+		if err != nil {
+			return err
+		}
+		p := make([]int32, len(vec))
+		for i := range vec {
+			p[i] = int32(vec[i])
+		}
+		v = p
+
+	case DTVec2, DTVec3, DTVec4:
+		var vec Vec
+		vec, err = vecValue(attr)
+		if err != nil {
+			return err
+		}
+		p := make([]float32, len(vec))
+		for i := range vec {
+			p[i] = float32(vec[i])
+		}
+		v = p
+
+	case DTMat2, DTMat3, DTMat3x4, DTMat4x3, DTMat4:
+		m, ok := attr.Value.(*Mat)
+		if !ok {
+			return valueError(attr)
+		}
+		var (
+			M         = (*mat.Dense)(m)
+			rows, col = M.Dims()
+			p         = make([]float32, 0, rows*col)
+		)
+		wantRows, _ := attr.GetRows()
+		wantCol, _ := attr.GetColumns()
+		if rows != wantRows || col != wantCol {
+			return fmt.Errorf("attribute %s: %dx%d matrix does not match type %v", attr.Name, rows, col, attr.Type)
+		}
+		// Matrices are stored column by column
+		for c := 0; c < col; c++ {
+			for r := 0; r < rows; r++ {
+				p = append(p, float32(M.At(r, c)))
+			}
+		}
+		v = p
+
+	case DTBool:
+		v, err = attrValue(attr, false)
+
+	case DTULongLong:
+		v, err = attrValue(attr, uint64(0))
+
+	case DTLong, DTInt64:
+		v, err = attrValue(attr, int64(0))
+
+	case DTInt8:
+		v, err = attrValue(attr, int8(0))
+
+	case DTUUID:
+		u, ok := attr.Value.(uuid.UUID)
+		if !ok {
+			return valueError(attr)
+		}
+		p := UUIDToBytes(u, guidMode)
+		if endianness == binary.BigEndian {
+			reverse(p[:4])
+			reverse(p[4:6])
+			reverse(p[6:8])
+		}
+		v = p
+
+	default:
+		// Strings are serialized differently for each file format and should be
+		// handled by the format-specific WriteAttribute()
+		return fmt.Errorf("writeAttribute() not implemented for type %v", attr.Type)
+	}
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, endianness, v)
+}
+
+func valueError(attr NodeAttribute) error {
+	return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+}
+
+// attrValue returns the value of attr if it has the same type as want
+func attrValue(attr NodeAttribute, want interface{}) (interface{}, error) {
+	if reflect.TypeOf(attr.Value) != reflect.TypeOf(want) {
+		return nil, valueError(attr)
+	}
+	return attr.Value, nil
+}
+
+func ivecValue(attr NodeAttribute) (Ivec, error) {
+	vec, ok := attr.Value.(Ivec)
+	if !ok {
+		return nil, valueError(attr)
+	}
+	if col, _ := attr.GetColumns(); len(vec) != col {
+		return nil, fmt.Errorf("attribute %s: %d values do not match type %v", attr.Name, len(vec), attr.Type)
+	}
+	return vec, nil
+}
+
+func vecValue(attr NodeAttribute) (Vec, error) {
+	vec, ok := attr.Value.(Vec)
+	if !ok {
+		return nil, valueError(attr)
+	}
+	if col, _ := attr.GetColumns(); len(vec) != col {
+		return nil, fmt.Errorf("attribute %s: %d values do not match type %v", attr.Name, len(vec), attr.Type)
+	}
+	return vec, nil
+}
+
+// WriteTranslatedString is the inverse of ReadTranslatedString
+func WriteTranslatedString(w io.Writer, str TranslatedString, endianness binary.ByteOrder, version FileVersion, engineVersion uint32) error {
+	var err error
+	if version >= VerBG3 || engineVersion == 0x4000001d {
+		err = binary.Write(w, endianness, str.Version)
+	} else {
+		err = binary.Write(w, endianness, int32(len(str.Value)+1))
+		if err == nil {
+			err = WriteCString(w, str.Value)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, endianness, int32(len(str.Handle)+1))
+	if err != nil {
+		return err
+	}
+	return WriteCString(w, str.Handle)
+}
+
+// WriteTranslatedFSString is the inverse of ReadTranslatedFSString
+func WriteTranslatedFSString(w io.Writer, str TranslatedFSString, endianness binary.ByteOrder, version FileVersion) error {
+	var err error
+	if version >= VerBG3 {
+		err = binary.Write(w, endianness, str.Version)
+	} else {
+		err = binary.Write(w, endianness, int32(len(str.Value)+1))
+		if err == nil {
+			err = WriteCString(w, str.Value)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, endianness, int32(len(str.Handle)+1))
+	if err != nil {
+		return err
+	}
+	err = WriteCString(w, str.Handle)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, endianness, int32(len(str.Arguments)))
+	if err != nil {
+		return err
+	}
+	for _, arg := range str.Arguments {
+		err = binary.Write(w, endianness, int32(len(arg.Key)+1))
+		if err != nil {
+			return err
+		}
+		err = WriteCString(w, arg.Key)
+		if err != nil {
+			return err
+		}
+
+		err = WriteTranslatedFSString(w, arg.String, endianness, version)
+		if err != nil {
+			return err
+		}
+
+		err = binary.Write(w, endianness, int32(len(arg.Value)+1))
+		if err != nil {
+			return err
+		}
+		err = WriteCString(w, arg.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
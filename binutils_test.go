@@ -0,0 +1,119 @@
+package lsgo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// zstdFrame is zstdText compressed by the zstd command at level 19, with a
+// checksum. Only the decoder is vendored, so the frames can't be made by the
+// test
+const zstdFrame = "28b52ffd642906ed050022c91916804d1b4066183613e88d50bc9248e4a64d8399410f01b8b8ac56aff9e3ed5ff7f4cf" +
+	"ee550b95f5364ff1feb3bbe54bbb5b7b29856aa717adf7cf662f73ee2d9b52aa9869c4fdf5ee366083c34c4c21d8c03c" +
+	"89115810490bd32807f32444103152e2400f830457a811f0f61efb19a097a43a11281801113ac28305e23efe02a031fc" +
+	"5affd5e44704e1395140e3c66fafba52238083163990d50751fa219385a20a8a16c8a4facf10c2042670084398c00958" +
+	"0609a32640ab027dc259ad"
+
+// zstdFast is zstdText compressed at level 1 without a checksum
+const zstdFast = "28b52ffd6029069d0600f2c91b199025ea805e442a1d141de04fd960cb7b6f29654a81baab8312c331926cf2282cfde8" +
+	"3e2fbeee68bfdffcf7ae37b96ce89e9319efdb55a3f2a17db5bee6b64d55c4fe5a86de4b46ad7310edae66e947fbed50" +
+	"46924d1e4519a92003a2888509480100422a88805892419201250b0169a831dcd7633f03b0a3540711284861168b9540" +
+	"de94f37634861d9b618e6698a319ede83460a63faa74c521639a43c2141ba0a304fe87b344decf76cec3663dccd5bacc" +
+	"0fe53a26dbab9133a663a51bdf7ea047f13e0be974cec1c286ab0960ab"
+
+// zstdMulti is the first 916 bytes of zstdText and the rest compressed as
+// two frames at level 19
+const zstdMulti = "28b52ffd649402550400b2451211907db096cbb6eea5ea02a5ffe1ee939e0146b92784ca7137aaf8214e860bf724dca8" +
+	"915c20744e31c64b51849c31883b10ccfe7eb704fb2b5f10216bfa75c2bfb285fc5a5a7c3f062fa81160778ffd0c9095" +
+	"a474112828611611f605047efd0465c4ab3c8be442156d44fd1afa46e8aba0df00dde9f91949baa45891aa48e776d086" +
+	"6a02fc2ae8379cee" +
+	"28b52ffd6495020d0400a2451211907db096fadc6fe450e411d3c3dd273d032491473e4e32a63c118f46f121de3d1d81" +
+	"88395e4c22c53cf9f1c207128e2f85a02c4b376dca2c7aa30b6284ac27adf2d11b59c854471b33a811e0b33fbb0160b9" +
+	"b406112838011182c28a147efe9a835510592b226b8ac8da3041233b2c14071392068b783746a9a80eb0ab802e2752"
+
+// zstdBlocks is zstdText repeated 200 times compressed at level 19, it is
+// larger than the 128KiB limit of a block
+const zstdBlocks = "28b52ffda4089805000c060042c81714902d36c00bc68e997a2ba596dddddd9b7677b670c036f2b755f3b08e2d6f75ef" +
+	"65dc6ba7e25d193fcbac7fde457d365eef67af9febd9c6df55c5cc23f69d9add0fd4e03013530835304f62041544d2c2" +
+	"34cac13c0911448c9438d0c3200156a811905f8ffd0c103749e5181270900826113cb3c040f5fdffefd4f82c8fec8bd5" +
+	"6b34864019447951262612094118610ddbc38dec138b1c8dc60651271b72b9101e1f446140f874fe611f4feddeccbc7c" +
+	"fb9d65e6356f1136464d00b60a540000000100fdff62fcb906024500000001000598390002387d1f59"
+
+// zstdSkippable is a skippable frame with the payload "skip"
+const zstdSkippable = "502a4d1804000000736b6970"
+
+func zstdText() []byte {
+	buf := &bytes.Buffer{}
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(buf, "Templates/GameObjects/%d/Name=Object%d;Level=%d\n", i, i%7, i*3%11)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressZstd(t *testing.T) {
+	text := zstdText()
+	tests := []struct {
+		name  string
+		frame string
+		want  []byte
+		// change is applied to the decoded frame
+		change  func([]byte) []byte
+		size    int
+		wantErr bool
+	}{
+		{name: "level 19", frame: zstdFrame, want: text},
+		{name: "no checksum", frame: zstdFast, want: text},
+		{name: "two frames", frame: zstdMulti, want: text},
+		{name: "many blocks", frame: zstdBlocks, want: bytes.Repeat(text, 200)},
+		{name: "skippable frame", frame: zstdSkippable + zstdFrame, want: text},
+
+		// The last 4 bytes are the checksum
+		{name: "bad checksum", frame: zstdFrame, want: text, wantErr: true, change: func(b []byte) []byte {
+			b[len(b)-1] ^= 0xff
+			return b
+		}},
+		{name: "truncated", frame: zstdFrame, want: text, wantErr: true, change: func(b []byte) []byte {
+			return b[:len(b)-10]
+		}},
+		{name: "truncated second frame", frame: zstdMulti, want: text, wantErr: true, change: func(b []byte) []byte {
+			return b[:len(b)-10]
+		}},
+		{name: "bad magic", frame: zstdFrame, want: text, wantErr: true, change: func(b []byte) []byte {
+			b[0] ^= 0xff
+			return b
+		}},
+		{name: "too small", frame: zstdFrame, want: text, size: len(text) - 1, wantErr: true},
+		{name: "too large", frame: zstdFrame, want: text, size: len(text) + 1, wantErr: true},
+	}
+	flags := byte(MakeCompressionFlags(CMZstd, DefaultCompression))
+	for _, tt := range tests {
+		src, err := hex.DecodeString(tt.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.change != nil {
+			src = tt.change(src)
+		}
+		size := len(tt.want)
+		if tt.size != 0 {
+			size = tt.size
+		}
+
+		got, err := DecompressBytes(src, size, flags, false)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: DecompressBytes did not fail", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: DecompressBytes =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
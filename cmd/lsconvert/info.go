@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/lsb"
+	"git.narnian.us/lordwelch/lsgo/lsf"
+)
+
+// section is the size of one section of an LSF file
+type section struct {
+	Name             string  `json:"name"`
+	SizeOnDisk       uint32  `json:"size_on_disk"`
+	UncompressedSize uint32  `json:"uncompressed_size"`
+	Ratio            float64 `json:"ratio"`
+}
+
+type compression struct {
+	Method  string `json:"method"`
+	Level   string `json:"level,omitempty"`
+	Chunked bool   `json:"chunked"`
+}
+
+// fileInfo is the output of the info command for a single file
+type fileInfo struct {
+	File          string `json:"file"`
+	Format        string `json:"format"`
+	Version       uint32 `json:"version,omitempty"`
+	EngineVersion string `json:"engine_version"`
+
+	// LSF only
+	Compression *compression `json:"compression,omitempty"`
+	Extended    bool         `json:"extended,omitempty"`
+	Sections    []section    `json:"sections,omitempty"`
+
+	// LSB only
+	ByteOrder string `json:"byte_order,omitempty"`
+	Size      uint32 `json:"size,omitempty"`
+	Timestamp uint64 `json:"timestamp,omitempty"`
+
+	Names      int `json:"names"`
+	Nodes      int `json:"nodes"`
+	Attributes int `json:"attributes"`
+	Regions    int `json:"regions"`
+	Keys       int `json:"keys,omitempty"`
+
+	// Set if the header could be read but the rest of the file could not
+	Error string `json:"error,omitempty"`
+}
+
+// info prints the header and section information of the files in args
+func info(args []string) int {
+	var (
+		fs       = flag.NewFlagSet("info", flag.ExitOnError)
+		jsonOut  = fs.Bool("json", false, "print the information as JSON")
+		infos    []fileInfo
+		exitCode int
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lsconvert info [-json] file...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	for _, path := range fs.Args() {
+		fi, err := readInfo(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		if fi.Error != "" {
+			exitCode = 1
+		}
+		infos = append(infos, fi)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if infos == nil {
+			infos = []fileInfo{}
+		}
+		_ = enc.Encode(infos)
+		return exitCode
+	}
+	for i, fi := range infos {
+		if i > 0 {
+			fmt.Println()
+		}
+		printInfo(os.Stdout, fi)
+	}
+	return exitCode
+}
+
+func readInfo(path string) (fileInfo, error) {
+	fi := fileInfo{File: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fi, err
+	}
+	defer f.Close()
+
+	signature := make([]byte, 4)
+	_, err = io.ReadFull(f, signature)
+	if err != nil {
+		return fi, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return fi, err
+	}
+
+	switch string(signature) {
+	case lsf.Signature:
+		return lsfInfo(fi, f)
+	case lsb.Signature, lsb.PreBG3Signature:
+		return lsbInfo(fi, f)
+	default:
+		return fi, fmt.Errorf("%w: info supports lsf and lsb files", lsgo.ErrFormat)
+	}
+}
+
+func formatMetadata(m lsgo.LSMetadata) string {
+	return fmt.Sprintf("%d.%d.%d.%d", m.Major, m.Minor, m.Revision, m.Build)
+}
+
+func newSection(name string, onDisk, uncompressed uint32) section {
+	// A size on disk of 0 means the section is stored uncompressed
+	if onDisk == 0 {
+		onDisk = uncompressed
+	}
+	s := section{
+		Name:             name,
+		SizeOnDisk:       onDisk,
+		UncompressedSize: uncompressed,
+	}
+	if uncompressed > 0 {
+		s.Ratio = float64(onDisk) / float64(uncompressed)
+	}
+	return s
+}
+
+func lsfInfo(fi fileInfo, r io.ReadSeeker) (fileInfo, error) {
+	var hdr lsf.Header
+	err := hdr.Read(r)
+	if err != nil {
+		return fi, err
+	}
+
+	fi.Format = "lsf"
+	fi.Version = uint32(hdr.Version)
+	fi.EngineVersion = formatMetadata(hdr.Metadata())
+	fi.Extended = hdr.Extended == 1
+
+	method := lsgo.CompressionFlagsToMethod(hdr.CompressionFlags)
+	fi.Compression = &compression{
+		Method:  method.String(),
+		Chunked: hdr.Version >= lsgo.VerChunkedCompress && method == lsgo.CMLZ4,
+	}
+	if method != lsgo.CMNone {
+		fi.Compression.Level = lsgo.CompressionLevel(hdr.CompressionFlags & 0xf0).String()
+	}
+
+	fi.Sections = []section{
+		newSection("names", hdr.StringsSizeOnDisk, hdr.StringsUncompressedSize),
+		newSection("nodes", hdr.NodesSizeOnDisk, hdr.NodesUncompressedSize),
+		newSection("attributes", hdr.AttributesSizeOnDisk, hdr.AttributesUncompressedSize),
+		newSection("values", hdr.ValuesSizeOnDisk, hdr.ValuesUncompressedSize),
+	}
+	if hdr.Version >= lsgo.VerBG3AdditionalBlob {
+		fi.Sections = append(fi.Sections, newSection("keys", hdr.KeysSizeOnDisk, hdr.KeysUncompressedSize))
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return fi, err
+	}
+	f, err := lsf.Open(r)
+	if err != nil {
+		fi.Error = err.Error()
+		return fi, nil
+	}
+	fi.Names = f.NameCount()
+	fi.Nodes = f.NodeCount()
+	fi.Attributes = f.AttributeCount()
+	fi.Regions = len(f.RegionNames())
+	fi.Keys = f.KeyCount()
+	return fi, nil
+}
+
+func lsbInfo(fi fileInfo, r io.ReadSeeker) (fileInfo, error) {
+	var hdr lsb.Header
+	err := hdr.Read(r)
+	if err != nil {
+		return fi, err
+	}
+
+	fi.Format = "lsb"
+	fi.EngineVersion = formatMetadata(hdr.Version)
+	fi.ByteOrder = hdr.ByteOrder().String()
+	fi.Size = hdr.Size
+	fi.Timestamp = hdr.Version.Timestamp
+
+	d, err := lsb.ReadLSBDictionary(r, hdr.ByteOrder(), lsgo.Options{})
+	if err != nil {
+		fi.Error = err.Error()
+		return fi, nil
+	}
+	fi.Names = len(d)
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return fi, err
+	}
+	res, err := lsb.Read(r)
+	if err != nil {
+		fi.Error = err.Error()
+		return fi, nil
+	}
+	fi.Regions = len(res.Regions)
+	for _, region := range res.Regions {
+		countNodes(region, &fi)
+	}
+	return fi, nil
+}
+
+func countNodes(n *lsgo.Node, fi *fileInfo) {
+	fi.Nodes++
+	fi.Attributes += len(n.Attributes)
+	for _, c := range n.Children {
+		countNodes(c, fi)
+	}
+}
+
+func printInfo(w io.Writer, fi fileInfo) {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "File:\t%s\n", fi.File)
+	fmt.Fprintf(tw, "Format:\t%s\n", fi.Format)
+	if fi.Version != 0 {
+		fmt.Fprintf(tw, "Version:\t%d\n", fi.Version)
+	}
+	fmt.Fprintf(tw, "Engine version:\t%s\n", fi.EngineVersion)
+	if fi.Compression != nil {
+		c := fi.Compression.Method
+		if fi.Compression.Level != "" {
+			c += ", " + fi.Compression.Level
+		}
+		if fi.Compression.Chunked {
+			c += ", chunked"
+		}
+		fmt.Fprintf(tw, "Compression:\t%s\n", c)
+		fmt.Fprintf(tw, "Extended:\t%t\n", fi.Extended)
+	}
+	if fi.ByteOrder != "" {
+		fmt.Fprintf(tw, "Byte order:\t%s\n", fi.ByteOrder)
+		fmt.Fprintf(tw, "Size:\t%d\n", fi.Size)
+		fmt.Fprintf(tw, "Timestamp:\t%d\n", fi.Timestamp)
+	}
+	fmt.Fprintf(tw, "Names:\t%d\n", fi.Names)
+	fmt.Fprintf(tw, "Nodes:\t%d\n", fi.Nodes)
+	fmt.Fprintf(tw, "Attributes:\t%d\n", fi.Attributes)
+	fmt.Fprintf(tw, "Regions:\t%d\n", fi.Regions)
+	if fi.Keys != 0 {
+		fmt.Fprintf(tw, "Keys:\t%d\n", fi.Keys)
+	}
+	if fi.Error != "" {
+		fmt.Fprintf(tw, "Error:\t%s\n", fi.Error)
+	}
+	_ = tw.Flush()
+
+	if len(fi.Sections) > 0 {
+		var onDisk, uncompressed uint32
+		b.WriteString("\n")
+		tw = tabwriter.NewWriter(&b, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Section\tOn disk\tUncompressed\tRatio\t")
+		for _, s := range fi.Sections {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.3f\t\n", s.Name, s.SizeOnDisk, s.UncompressedSize, s.Ratio)
+			onDisk += s.SizeOnDisk
+			uncompressed += s.UncompressedSize
+		}
+		total := newSection("total", onDisk, uncompressed)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.3f\t\n", total.Name, total.SizeOnDisk, total.UncompressedSize, total.Ratio)
+		_ = tw.Flush()
+	}
+	_, _ = b.WriteTo(w)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/lsb"
+	"git.narnian.us/lordwelch/lsgo/lsf"
+)
+
+// bytes shown on each line of the hexdump
+const hexWidth = 16
+
+// layout prints the position and value of every field of the files in args
+func layout(args []string) int {
+	var (
+		fs       = flag.NewFlagSet("layout", flag.ExitOnError)
+		jsonOut  = fs.Bool("json", false, "print the layout as JSON instead of an annotated hexdump")
+		exitCode int
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lsconvert layout [-json] file...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, path := range fs.Args() {
+		l, err := readLayout(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		if *jsonOut {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "\t")
+			_ = enc.Encode(l)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", path)
+		hexdump(w, l)
+	}
+	return exitCode
+}
+
+func readLayout(path string) (lsgo.Layout, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return lsgo.Layout{}, err
+	}
+	defer f.Close()
+
+	signature := make([]byte, 4)
+	_, err = io.ReadFull(f, signature)
+	if err != nil {
+		return lsgo.Layout{}, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return lsgo.Layout{}, err
+	}
+
+	switch string(signature) {
+	case lsf.Signature:
+		return lsf.ReadLayout(f)
+	case lsb.Signature, lsb.PreBG3Signature:
+		return lsb.ReadLayout(f)
+	default:
+		return lsgo.Layout{}, fmt.Errorf("%w: layout supports lsf and lsb files", lsgo.ErrFormat)
+	}
+}
+
+// hexdump writes the data of every section of l, annotated with the fields
+// stored in it. Bytes that do not belong to a field are marked as unparsed
+func hexdump(w io.Writer, l lsgo.Layout) {
+	for _, s := range l.Sections {
+		var (
+			fields []lsgo.LayoutField
+			base   = s.Offset
+			pos    int64
+		)
+		if s.Compressed {
+			base = 0
+			fmt.Fprintf(w, "\n== %s: %d bytes at %#x, %d bytes uncompressed, offsets are in the uncompressed data ==\n", s.Name, s.SizeOnDisk, s.Offset, len(s.Data))
+		} else {
+			fmt.Fprintf(w, "\n== %s: %d bytes at %#x ==\n", s.Name, s.SizeOnDisk, s.Offset)
+		}
+
+		for _, f := range l.Fields {
+			if f.Section == s.Name {
+				fields = append(fields, f)
+			}
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			return fields[i].Offset < fields[j].Offset
+		})
+
+		for _, f := range fields {
+			start := f.Offset - base
+			if start > pos {
+				dumpLines(w, s.Data, base, pos, start, "(unparsed)")
+			}
+			dumpLines(w, s.Data, base, start, start+f.Size, annotation(f))
+			if start+f.Size > pos {
+				pos = start + f.Size
+			}
+		}
+		if int64(len(s.Data)) > pos {
+			dumpLines(w, s.Data, base, pos, int64(len(s.Data)), "(unparsed)")
+		}
+	}
+}
+
+func annotation(f lsgo.LayoutField) string {
+	value := f.Value
+	if len(value) > 64 {
+		value = value[:61] + "..."
+	}
+	if strings.IndexFunc(value, func(r rune) bool { return !strconv.IsPrint(r) }) >= 0 {
+		value = strconv.Quote(value)
+	}
+
+	s := f.Name + " = " + value
+	if f.Node != "" {
+		s += "  [" + f.Node
+		if f.Attribute != "" {
+			s += " @" + f.Attribute
+		}
+		s += "]"
+	}
+	return s
+}
+
+// dumpLines writes data[start:end] hexWidth bytes per line, the note is written after the first line
+func dumpLines(w io.Writer, data []byte, base, start, end int64, note string) {
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	if start >= end {
+		fmt.Fprintf(w, "%08x  %-*s  %s\n", base+start, hexWidth*3-1, "", note)
+		return
+	}
+	for pos := start; pos < end; pos += hexWidth {
+		var (
+			line = data[pos:min64(pos+hexWidth, end)]
+			hex  = make([]string, len(line))
+		)
+		for i, b := range line {
+			hex[i] = fmt.Sprintf("%02x", b)
+		}
+		fmt.Fprintf(w, "%08x  %-*s  %s\n", base+pos, hexWidth*3-1, strings.Join(hex, " "), note)
+		note = ""
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
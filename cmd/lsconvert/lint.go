@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/lint"
+)
+
+// lintFiles checks the files in args, and the files in any directories, with the lint rules
+func lintFiles(args []string) int {
+	var (
+		fs       = flag.NewFlagSet("lint", flag.ExitOnError)
+		jsonOut  = fs.Bool("json", false, "print the problems as JSON")
+		locaList = fs.String("loca", "", "comma separated localization `files`, enables the missing-handle rule")
+		rules    = fs.String("rules", "", "comma separated `rule=severity` overrides, a severity of off disables the rule")
+		failAt   = fs.String("fail", "error", "exit with status 1 if there is a problem of at least this `severity`")
+		exitCode int
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lsconvert lint [-json] [-loca files] [-rules rule=severity,...] [-fail severity] file...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	fail, err := lint.ParseSeverity(*failAt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lsconvert: -fail: %v\n", err)
+		return 2
+	}
+	l := &lint.Linter{
+		Rules:    lint.DefaultRules(),
+		Severity: make(map[string]lint.Severity),
+	}
+	for _, rule := range strings.Split(*rules, ",") {
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			fmt.Fprintf(os.Stderr, "lsconvert: -rules: %q is not rule=severity\n", rule)
+			return 2
+		}
+		l.Severity[parts[0]], err = lint.ParseSeverity(parts[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: -rules: %v\n", err)
+			return 2
+		}
+	}
+	if *locaList != "" {
+		loca := make(lint.Loca)
+		for _, path := range strings.Split(*locaList, ",") {
+			err = readLoca(loca, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", path, err)
+				return 1
+			}
+		}
+		l.Rules = append(l.Rules, lint.MissingHandle(loca))
+	}
+
+	problems := []lint.Problem{}
+	for _, arg := range fs.Args() {
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			f, err := readLintFile(path)
+			switch {
+			case err == nil:
+				problems = append(problems, l.Lint(f)...)
+			case path != arg && (errors.Is(err, lsgo.ErrFormat) || errors.As(err, &lsgo.HeaderError{})):
+				// Not a resource, only files given as arguments must be one
+			default:
+				fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", path, err)
+				exitCode = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: %v\n", err)
+			exitCode = 1
+		}
+	}
+
+	for _, p := range problems {
+		if p.Severity >= fail {
+			exitCode = 1
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		_ = enc.Encode(problems)
+		return exitCode
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	return exitCode
+}
+
+// readLintFile decodes the resource at path along with the suppressions written in it
+func readLintFile(path string) (*lint.File, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := lsgo.DecodeWithOptions(lsgo.NewSliceReader(b), lsgo.Options{Filename: path})
+	if err != nil {
+		return nil, err
+	}
+	return &lint.File{
+		Path:         path,
+		Resource:     &res,
+		Suppressions: lint.ParseSuppressions(b),
+	}, nil
+}
+
+// readLoca adds the strings of the localization file at path to loca
+func readLoca(loca lint.Loca, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	l, err := lint.ReadLoca(f)
+	if err != nil {
+		return err
+	}
+	loca.Add(l)
+	return nil
+}
@@ -2,43 +2,85 @@ package main
 
 import (
 	"bytes"
-	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"git.narnian.us/lordwelch/lsgo"
 	_ "git.narnian.us/lordwelch/lsgo/lsb"
 	_ "git.narnian.us/lordwelch/lsgo/lsf"
+	_ "git.narnian.us/lordwelch/lsgo/lsj"
+	_ "git.narnian.us/lordwelch/lsgo/lsx"
 
 	"github.com/go-kit/kit/log"
 	"github.com/kr/pretty"
 )
 
 var (
-	write         = flag.Bool("w", false, "replace the file with xml data")
-	printXML      = flag.Bool("x", false, "print xml to stdout")
+	write         = flag.Bool("w", false, "write the converted file next to the input, replacing it if the extension does not change")
+	backup        = flag.Bool("backup", false, "keep a .bak copy of files that are replaced")
+	outDir        = flag.String("out", "", "write converted files to `dir`, mirroring the input tree")
+	format        = flag.String("o", "lsx", "output `format`, one of "+strings.Join(lsgo.Encoders(), ", "))
+	printOutput   = flag.Bool("x", false, "print the converted file to stdout")
 	printResource = flag.Bool("R", false, "print the resource struct to stderr")
 	recurse       = flag.Bool("r", false, "recurse into directories")
 	logging       = flag.Bool("l", false, "enable logging to stderr")
 	parts         = flag.String("p", "", "parts to filter logging for, comma separated")
+	jobs          = flag.Int("j", runtime.NumCPU(), "number of files to convert in parallel")
 )
 
 func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: lsconvert [flags] file...\n       lsconvert info [-json] file...\n       lsconvert layout [-json] file...\n       lsconvert schema infer [-j n] dir...\n       lsconvert schema validate -schema file file...\n       lsconvert lint [-json] [-loca files] file...\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 	if *logging {
 		lsgo.Logger = lsgo.NewFilter(map[string][]string{
 			"part": strings.Split(*parts, ","),
-		}, log.NewLogfmtLogger(os.Stderr))
+		}, log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)))
 	}
 }
 
+// job is a file to convert
+type job struct {
+	path string
+
+	// path relative to the argument it was found in, used to mirror the input tree
+	rel string
+
+	// walked is set for files found by walking a directory, they are skipped
+	// if they are not a resource
+	walked bool
+}
+
 func main() {
+	var files []job
+	switch flag.Arg(0) {
+	case "info":
+		os.Exit(info(flag.Args()[1:]))
+	case "layout":
+		os.Exit(layout(flag.Args()[1:]))
+	case "schema":
+		os.Exit(schema(flag.Args()[1:]))
+	case "lint":
+		os.Exit(lintFiles(flag.Args()[1:]))
+	}
+	if !supportedEncoder(*format) {
+		fmt.Fprintf(os.Stderr, "lsconvert: unknown output format %q, must be one of %s\n", *format, strings.Join(lsgo.Encoders(), ", "))
+		os.Exit(2)
+	}
+	if *write && *outDir != "" {
+		fmt.Fprintln(os.Stderr, "lsconvert: -w and -out cannot be used together")
+		os.Exit(2)
+	}
 	for _, v := range flag.Args() {
 		fi, err := os.Stat(v)
 		if err != nil {
@@ -47,11 +89,7 @@ func main() {
 		}
 		switch {
 		case !fi.IsDir():
-			err = openLSF(v)
-			if err != nil && !errors.As(err, &lsgo.HeaderError{}) {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
-			}
+			files = append(files, job{v, filepath.Base(v), false})
 
 		case *recurse:
 			_ = filepath.Walk(v, func(path string, info os.FileInfo, err error) error {
@@ -64,10 +102,11 @@ func main() {
 					}
 					return nil
 				}
-				err = openLSF(path)
-				if err != nil && !errors.As(err, &lsgo.HeaderError{}) {
-					fmt.Fprintln(os.Stderr, err)
+				rel, err := filepath.Rel(v, path)
+				if err != nil {
+					rel = filepath.Base(path)
 				}
+				files = append(files, job{path, rel, true})
 				return nil
 			})
 
@@ -76,148 +115,203 @@ func main() {
 			os.Exit(1)
 		}
 	}
+
+	s := convertAll(files, *jobs)
+	if len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "lsconvert: %d converted, %d skipped, %d failed\n", s.converted, s.skipped, s.failed)
+	}
+	if s.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// result is the output of converting a single file
+type result struct {
+	stdout, stderr bytes.Buffer
+	err            error
+}
+
+type summary struct {
+	converted, skipped, failed int
 }
 
-func openLSF(filename string) error {
+// convertAll converts files using n workers. Output is written to stdout and
+// stderr in the same order as files regardless of the order the conversions
+// finish. At most 2n files are converted ahead of the one being printed, so
+// a slow file doesn't leave the output of every file after it in memory
+func convertAll(files []job, n int) summary {
+	if n < 1 {
+		n = 1
+	}
 	var (
-		l   *lsgo.Resource
-		err error
-		n   string
-		f   interface {
-			io.Writer
-			io.StringWriter
-		}
+		s       summary
+		results = make([]chan *result, len(files))
+		queue   = make(chan int)
+		window  = make(chan struct{}, 2*n)
 	)
-	l, err = readLSF(filename)
-	if err != nil {
-		return fmt.Errorf("reading LSF file %s failed: %w", filename, err)
+	for i := range results {
+		results[i] = make(chan *result, 1)
 	}
-	if *printResource {
-		pretty.Log(l)
+	for w := 0; w < n; w++ {
+		go func() {
+			for i := range queue {
+				res := &result{}
+				res.err = convert(files[i], &res.stdout, &res.stderr)
+				results[i] <- res
+			}
+		}()
 	}
-	if *printXML || *write {
-		n, err = marshalXML(l)
-		if err != nil {
-			return fmt.Errorf("creating XML from LSF file %s failed: %w", filename, err)
+	go func() {
+		for i := range files {
+			window <- struct{}{}
+			queue <- i
 		}
+		close(queue)
+	}()
 
-		if *write {
-			f, err = os.OpenFile(filename, os.O_TRUNC|os.O_RDWR, 0o666)
-			if err != nil {
-				return fmt.Errorf("writing XML from LSF file %s failed: %w", filename, err)
-			}
-		} else if *printXML {
-			f = os.Stdout
+	for i, c := range results {
+		res := <-c
+		_, _ = res.stdout.WriteTo(os.Stdout)
+		_, _ = res.stderr.WriteTo(os.Stderr)
+		<-window
+		switch {
+		case res.err == nil:
+			s.converted++
+		case errors.As(res.err, &lsgo.HeaderError{}),
+			files[i].walked && errors.Is(res.err, lsgo.ErrFormat):
+			// Not a resource, only files given as arguments must be one
+			s.skipped++
+		default:
+			s.failed++
+			fmt.Fprintln(os.Stderr, res.err)
 		}
+	}
+	return s
+}
 
-		err = writeXML(f, n)
-		fmt.Fprint(f, "\n")
-		if err != nil {
-			return fmt.Errorf("writing XML from LSF file %s failed: %w", filename, err)
+// textFormats are the output formats that are text
+var textFormats = map[string]bool{
+	"lsj": true,
+	"lsx": true,
+}
+
+func supportedEncoder(name string) bool {
+	for _, e := range lsgo.Encoders() {
+		if e == name {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func readLSF(filename string) (*lsgo.Resource, error) {
-	var (
-		l    lsgo.Resource
-		r    io.ReadSeeker
-		file *os.File
-		fi   os.FileInfo
-		err  error
-	)
-	switch filepath.Ext(filename) {
-	case ".lsf", ".lsb":
-		var b []byte
-		fi, err = os.Stat(filename)
-		if err != nil {
-			return nil, err
-		}
-		// Arbitrary size, no lsf file should reach 100 MB (I haven't found one over 90 KB)
-		// and if you don't have 100 MB of ram free you shouldn't be using this
-		if fi.Size() <= 100*1024*1024 {
-			b, err = ioutil.ReadFile(filename)
-			if err != nil {
-				return nil, err
-			}
-			r = bytes.NewReader(b)
-			break
-		}
-		fallthrough
+// outputPath returns the path the converted file of j is written to, or "" if it is not written to a file
+func outputPath(j job) string {
+	switch {
+	case *outDir != "":
+		return withExt(filepath.Join(*outDir, j.rel), *format)
+	case *write:
+		return withExt(j.path, *format)
 	default:
-		b := make([]byte, 4)
-		file, err = os.Open(filename)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
+		return ""
+	}
+}
+
+// withExt replaces the extension of path with format
+func withExt(path, format string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + format
+}
 
-		_, err = file.Read(b)
+func convert(j job, stdout, stderr io.Writer) error {
+	l, err := readLSF(j.path)
+	if err != nil {
+		return fmt.Errorf("reading LSF file %s failed: %w", j.path, err)
+	}
+	if *printResource {
+		pretty.Fprintf(stderr, "%# v\n", l)
+	}
+	if *printOutput {
+		var b bytes.Buffer
+		err = lsgo.Encode(&b, l, *format)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("converting %s to %s failed: %w", j.path, *format, err)
 		}
-		if !lsgo.SupportedFormat(b) {
-			return nil, lsgo.ErrFormat
+		// Separates the files when more than one is printed, binary
+		// formats are printed unchanged
+		if textFormats[*format] && b.Len() > 0 && b.Bytes()[b.Len()-1] != '\n' {
+			b.WriteByte('\n')
 		}
-
-		_, err = file.Seek(0, io.SeekStart)
+		_, _ = b.WriteTo(stdout)
+	}
+	if path := outputPath(j); path != "" {
+		err = writeFile(path, l)
 		if err != nil {
-			return nil, err
-		}
-		fi, _ = os.Stat(filename)
-
-		// I have never seen a valid "ls*" file over 90 KB
-		if fi.Size() < 1*1024*1024 {
-			b, err = ioutil.ReadAll(file)
-			if err != nil {
-				return nil, err
-			}
-			r = bytes.NewReader(b)
-		} else {
-			r = file
+			return fmt.Errorf("converting %s to %s failed: %w", j.path, path, err)
 		}
 	}
+	return nil
+}
 
-	l, _, err = lsgo.Decode(r)
+// writeFile encodes res to a temporary file which is then renamed to path,
+// so that path is never left partially written
+func writeFile(path string, res *lsgo.Resource) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o777)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &l, nil
-}
-
-func marshalXML(l *lsgo.Resource) (string, error) {
-	var (
-		v   []byte
-		err error
-	)
-	v, err = xml.MarshalIndent(struct {
-		*lsgo.Resource
-		XMLName string `xml:"save"`
-	}{l, ""}, "", "\t")
+	// A replaced file keeps its mode, a new file gets the same mode as one
+	// made by os.Create
+	perm := os.FileMode(0o666)
+	fi, statErr := os.Stat(path)
+	if statErr == nil {
+		perm = fi.Mode().Perm()
+	}
+	f, err := createTemp(path, perm)
 	if err != nil {
-		return string(v), err
-	}
-	n := string(v)
-	n = strings.ReplaceAll(n, "></version>", " />")
-	n = strings.ReplaceAll(n, "></attribute>", " />")
-	n = strings.ReplaceAll(n, "></node>", " />")
-	n = strings.ReplaceAll(n, "false", "False")
-	n = strings.ReplaceAll(n, "true", "True")
-	n = strings.ReplaceAll(n, "&#39;", "'")
-	n = strings.ReplaceAll(n, "&#34;", "&quot;")
-	return n, nil
-}
+		return err
+	}
+	defer os.Remove(f.Name())
 
-func writeXML(f io.StringWriter, n string) error {
-	var err error
-	_, err = f.WriteString(strings.ToLower(xml.Header))
+	err = lsgo.Encode(f, res, *format)
+	if err == nil && statErr == nil {
+		// The umask applied when the file was created
+		err = f.Chmod(perm)
+	}
 	if err != nil {
+		f.Close()
 		return err
 	}
-	_, err = f.WriteString(n)
+	err = f.Close()
 	if err != nil {
 		return err
 	}
-	return nil
+	if *backup {
+		if _, err = os.Stat(path); err == nil {
+			err = os.Rename(path, path+".bak")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// createTemp creates a new file in the directory of path to be renamed to
+// path. Unlike ioutil.TempFile perm is used, less the umask
+func createTemp(path string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".")
+	for i := 0; ; i++ {
+		f, err := os.OpenFile(prefix+strconv.FormatUint(uint64(rand.Uint32()), 10), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+func readLSF(filename string) (*lsgo.Resource, error) {
+	l, _, err := lsgo.DecodeFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"git.narnian.us/lordwelch/lsgo"
+)
+
+// schema runs the schema subcommands
+func schema(args []string) int {
+	switch {
+	case len(args) > 0 && args[0] == "infer":
+		return schemaInfer(args[1:])
+	case len(args) > 0 && args[0] == "validate":
+		return schemaValidate(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: lsconvert schema infer [-j n] dir...\n       lsconvert schema validate -schema file file...")
+		return 2
+	}
+}
+
+// schemaInfer prints the schema of every resource found in args as JSON
+func schemaInfer(args []string) int {
+	var (
+		fs       = flag.NewFlagSet("schema infer", flag.ExitOnError)
+		n        = fs.Int("j", runtime.NumCPU(), "number of files to read in parallel")
+		exitCode int
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lsconvert schema infer [-j n] dir...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	var files []string
+	for _, dir := range fs.Args() {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: %v\n", err)
+			exitCode = 1
+		}
+	}
+
+	s := lsgo.NewSchema()
+	for res := range decodeAll(files, *n) {
+		switch {
+		case res.err == nil:
+			s.Add(res.res)
+		case errors.Is(res.err, lsgo.ErrFormat), errors.As(res.err, &lsgo.HeaderError{}):
+			// Not a resource
+		default:
+			fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", res.path, res.err)
+			exitCode = 1
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	_ = enc.Encode(s)
+	return exitCode
+}
+
+// schemaValidate checks the files in args against a schema printed by schema infer
+func schemaValidate(args []string) int {
+	var (
+		fs         = flag.NewFlagSet("schema validate", flag.ExitOnError)
+		schemaFile = fs.String("schema", "", "schema `file` printed by schema infer")
+		exitCode   int
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lsconvert schema validate -schema file file...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *schemaFile == "" {
+		fs.Usage()
+		return 2
+	}
+
+	b, err := ioutil.ReadFile(*schemaFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lsconvert: %v\n", err)
+		return 1
+	}
+	var s lsgo.Schema
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", *schemaFile, err)
+		return 1
+	}
+
+	for _, path := range fs.Args() {
+		res, err := readLSF(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lsconvert: %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		for _, verr := range lsgo.Validate(res, &s) {
+			fmt.Printf("%s: %v\n", path, verr)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// decoded is a file decoded by decodeAll
+type decoded struct {
+	path string
+	res  *lsgo.Resource
+	err  error
+}
+
+// decodeAll decodes files using n workers, the results are sent in the
+// order the decodes finish
+func decodeAll(files []string, n int) <-chan decoded {
+	var (
+		wg      sync.WaitGroup
+		queue   = make(chan string)
+		results = make(chan decoded)
+	)
+	if n < 1 {
+		n = 1
+	}
+	wg.Add(n)
+	for w := 0; w < n; w++ {
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				res, err := readLSF(path)
+				results <- decoded{path, res, err}
+			}
+		}()
+	}
+	go func() {
+		for _, path := range files {
+			queue <- path
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
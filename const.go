@@ -20,6 +20,15 @@ const (
 	// BG3 version, no changes found so far apart from version numbering
 	VerBG3
 
+	// BG3 version with an extended header, the engine version is 64 bits
+	VerBG3ExtendedHeader
+
+	// BG3 version with additional blob sections in the header
+	VerBG3AdditionalBlob
+
+	// BG3 version with node keys
+	VerBG3NodeKeys
+
 	// Latest version supported by this library
 	MaxVersion = iota
 )
@@ -31,8 +40,44 @@ const (
 	CMNone
 	CMZlib
 	CMLZ4
+	CMZstd
+)
+
+// GUIDMode is how the 16 bytes of a DTUUID attribute map to a uuid.UUID
+type GUIDMode int
+
+const (
+	// The first three groups are stored little-endian, the same as a .NET Guid
+	GUIDStandard GUIDMode = iota
+
+	// The same as GUIDStandard, with the last 8 bytes also swapped in pairs.
+	// This is what LSLib uses for BG3 and marks with bswap_guids in LSX files
+	GUIDByteSwapped
 )
 
+// DefaultGUIDMode returns the GUIDMode LSLib uses for a resource with the given metadata
+func DefaultGUIDMode(m LSMetadata) GUIDMode {
+	if m.Major >= 4 {
+		return GUIDByteSwapped
+	}
+	return GUIDStandard
+}
+
+func (cm CompressionMethod) String() string {
+	switch cm {
+	case CMNone:
+		return "none"
+	case CMZlib:
+		return "zlib"
+	case CMLZ4:
+		return "lz4"
+	case CMZstd:
+		return "zstd"
+	default:
+		return "invalid"
+	}
+}
+
 type CompressionLevel int
 
 const (
@@ -41,10 +86,28 @@ const (
 	MaxCompression     CompressionLevel = 0x40
 )
 
+func (cl CompressionLevel) String() string {
+	switch cl {
+	case FastCompression:
+		return "fast"
+	case DefaultCompression:
+		return "default"
+	case MaxCompression:
+		return "max"
+	default:
+		return fmt.Sprintf("unknown (%#x)", int(cl))
+	}
+}
+
 var (
 	ErrVectorTooBig    = errors.New("the vector is too big cannot marshal to an xml element")
 	ErrInvalidNameKey  = errors.New("invalid name key")
 	ErrKeyDoesNotMatch = errors.New("key for this node does not match")
+	ErrRegionNotFound  = errors.New("region not found")
+	ErrSectionSize     = errors.New("section does not match the size in the header")
+	ErrAttributeSize   = errors.New("attribute value does not match its length")
+
+	ErrDecompressedSize = errors.New("decompressed data does not match the uncompressed size")
 )
 
 type HeaderError struct {
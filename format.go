@@ -2,10 +2,13 @@
 package lsgo
 
 import (
+	"bufio"
 	"errors"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -13,10 +16,55 @@ import (
 // ErrFormat indicates that decoding encountered an unknown format.
 var ErrFormat = errors.New("lsgo: unknown format")
 
-// A format holds an image format's name, magic header and how to decode it.
-type format struct {
-	name, magic string
-	decode      func(io.ReadSeeker) (Resource, error)
+// A Confidence is how certain a sniff function is that data is in its format
+type Confidence int
+
+const (
+	// NoMatch is returned for data that is not in the format
+	NoMatch Confidence = iota
+
+	// ExtensionMatch is used for a format that is only matched by the file
+	// extension given in Options.Filename
+	ExtensionMatch
+
+	// LikelyMatch is returned for data that may be in the format, e.g. any
+	// XML document for LSX
+	LikelyMatch
+
+	// CertainMatch is returned for data that is in the format, e.g. a matching
+	// magic number or an XML document with a <save> root for LSX
+	CertainMatch
+)
+
+// SniffLen is the most bytes given to a sniff function
+const SniffLen = 512
+
+// A Format describes how to recognise and decode a resource format
+type Format struct {
+	// Name is the name of the format, like "lsf" or "lsx"
+	Name string
+
+	// Magic is the magic prefix that identifies the format's encoding. The
+	// magic string can contain "?" wildcards that each match any one byte.
+	// Data that matches is a CertainMatch
+	Magic string
+
+	// Sniff is called with up to SniffLen bytes from the start of the data
+	// if Magic does not match. It may be nil
+	Sniff func(b []byte) Confidence
+
+	// Extensions are the file extensions of the format including the dot,
+	// like ".lsx". They break ties between formats that match with the same
+	// confidence and identify the format when no content matches
+	Extensions []string
+
+	// Decode is the function that decodes the encoded resource
+	Decode func(io.ReadSeeker, Options) (Resource, error)
+
+	// DecodeStream decodes the resource from a reader that can't seek. It is
+	// set for formats that don't need to seek, DecodeReader reads the whole
+	// input into memory for the others
+	DecodeStream func(io.Reader, Options) (Resource, error)
 }
 
 // Formats is the list of registered formats.
@@ -32,9 +80,23 @@ var (
 // Decode is the function that decodes the encoded image.
 // DecodeConfig is the function that decodes just its configuration.
 func RegisterFormat(name, magic string, decode func(io.ReadSeeker) (Resource, error)) {
+	RegisterDecoder(name, magic, func(r io.ReadSeeker, _ Options) (Resource, error) {
+		return decode(r)
+	})
+}
+
+// RegisterDecoder is the same as RegisterFormat for a decoder that accepts
+// the Options given to DecodeWithOptions
+func RegisterDecoder(name, magic string, decode func(io.ReadSeeker, Options) (Resource, error)) {
+	Register(Format{Name: name, Magic: magic, Decode: decode})
+}
+
+// Register registers f for use by Decode. A format may be registered more
+// than once, e.g. with a different magic for each version
+func Register(f Format) {
 	formatsMu.Lock()
-	formats, _ := atomicFormats.Load().([]format)
-	atomicFormats.Store(append(formats, format{name, magic, decode}))
+	formats, _ := atomicFormats.Load().([]Format)
+	atomicFormats.Store(append(formats, f))
 	formatsMu.Unlock()
 }
 
@@ -51,42 +113,233 @@ func match(magic string, b []byte) bool {
 	return true
 }
 
-// Sniff determines the format of r's data.
-func sniff(r io.ReadSeeker) format {
+// confidence returns how certain it is that b, the start of a file named
+// filename, is in the format f
+func (f Format) confidence(b []byte, filename string) Confidence {
+	if f.Magic != "" && len(b) >= len(f.Magic) && match(f.Magic, b[:len(f.Magic)]) {
+		return CertainMatch
+	}
+	if f.Sniff != nil {
+		if c := f.Sniff(b); c > NoMatch {
+			return c
+		}
+	}
+	if f.hasExtension(filename) {
+		return ExtensionMatch
+	}
+	return NoMatch
+}
+
+// hasExtension reports whether filename has one of the extensions of f
+func (f Format) hasExtension(filename string) bool {
+	if filename == "" {
+		return false
+	}
+	ext := filepath.Ext(filename)
+	for _, e := range f.Extensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the registered format b is most likely in. Formats that match
+// with the same confidence are ranked by the extension of filename and then
+// by registration order
+func best(b []byte, filename string) (Format, Confidence) {
 	var (
-		b   []byte = make([]byte, 4)
-		err error
+		bestFormat Format
+		bestConf   = NoMatch
+		bestExt    bool
 	)
-	formats, _ := atomicFormats.Load().([]format)
+	formats, _ := atomicFormats.Load().([]Format)
 	for _, f := range formats {
-		if len(b) < len(f.magic) {
-			fmt.Fprintln(os.Stderr, f.magic)
-			b = make([]byte, len(f.magic))
+		c := f.confidence(b, filename)
+		if c == NoMatch {
+			continue
 		}
-		_, err = r.Read(b)
-		_, _ = r.Seek(0, io.SeekStart)
-		if err == nil && match(f.magic, b) {
-			return f
+		ext := f.hasExtension(filename)
+		if c > bestConf || c == bestConf && ext && !bestExt {
+			bestFormat, bestConf, bestExt = f, c, ext
 		}
 	}
-	return format{}
+	return bestFormat, bestConf
 }
 
+// Sniff determines the format of r's data, r is left at the start
+func sniff(r io.ReadSeeker, filename string) (Format, error) {
+	b := make([]byte, SniffLen)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Format{}, err
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return Format{}, err
+	}
+	f, _ := best(b[:n], filename)
+	return f, nil
+}
+
+// Decode decodes a resource that has been encoded in a registered format.
+// The string returned is the format name used during format registration
 func Decode(r io.ReadSeeker) (Resource, string, error) {
-	f := sniff(r)
-	if f.decode == nil {
+	return DecodeWithOptions(r, Options{})
+}
+
+// DecodeWithOptions is the same as Decode using opts instead of the defaults
+func DecodeWithOptions(r io.ReadSeeker, opts Options) (Resource, string, error) {
+	f, err := sniff(r, opts.Filename)
+	if err != nil {
+		return Resource{}, "", err
+	}
+	if f.Decode == nil {
 		return Resource{}, "", ErrFormat
 	}
-	m, err := f.decode(r)
-	return m, f.name, err
+	m, err := f.Decode(r, opts)
+	return m, f.Name, err
 }
 
-func SupportedFormat(signature []byte) bool {
-	formats, _ := atomicFormats.Load().([]format)
-	for _, f := range formats {
-		if match(f.magic, signature) {
-			return true
+// DefaultMaxReaderSize is the most bytes DecodeReader reads into memory for
+// a format that can't be decoded from a stream if Limits.MaxSectionSize is 0
+const DefaultMaxReaderSize = 1 << 30
+
+// DecodeReader is the same as Decode for a reader that may not be able to
+// seek. If r is not an io.ReadSeeker the format is sniffed from the start of
+// r and formats that can't be decoded from a stream are read into memory,
+// use DecodeReaderWithOptions to bound how much is read
+func DecodeReader(r io.Reader) (Resource, string, error) {
+	return DecodeReaderWithOptions(r, Options{})
+}
+
+// DecodeReaderWithOptions is the same as DecodeReader using opts instead of
+// the defaults. If r is not an io.ReadSeeker the whole of it counts as a
+// single section, no more than opts.Limits.MaxSectionSize bytes are read and
+// a longer input is an ErrLimitExceeded. A format that has to be read into
+// memory is limited to DefaultMaxReaderSize if there is no limit
+func DecodeReaderWithOptions(r io.Reader, opts Options) (Resource, string, error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return DecodeWithOptions(rs, opts)
+	}
+	// Nothing past the limit is read from r, not even to sniff
+	if limit := opts.Limits.MaxSectionSize; limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	br := bufio.NewReaderSize(r, SniffLen)
+	b, err := br.Peek(SniffLen)
+	if err != nil && err != io.EOF {
+		return Resource{}, "", err
+	}
+	f, _ := best(b, opts.Filename)
+	if f.DecodeStream != nil {
+		res, err := f.DecodeStream(opts.Limits.Reader(br), opts)
+		return res, f.Name, err
+	}
+	if f.Decode == nil {
+		return Resource{}, "", ErrFormat
+	}
+
+	limits := opts.Limits
+	if limits.MaxSectionSize <= 0 {
+		limits.MaxSectionSize = DefaultMaxReaderSize
+	}
+	data, err := ioutil.ReadAll(limits.Reader(br))
+	if err != nil {
+		return Resource{}, f.Name, err
+	}
+	res, err := f.Decode(NewSliceReader(data), opts)
+	return res, f.Name, err
+}
+
+// DecodeAt is the same as Decode for the size bytes of r, e.g. an entry in a
+// package or a file that is shared with other readers
+func DecodeAt(r io.ReaderAt, size int64) (Resource, string, error) {
+	return DecodeAtWithOptions(r, size, Options{})
+}
+
+// DecodeAtWithOptions is the same as DecodeAt using opts instead of the
+// defaults, e.g. to give the name of a package entry in opts.Filename
+func DecodeAtWithOptions(r io.ReaderAt, size int64, opts Options) (Resource, string, error) {
+	return DecodeWithOptions(io.NewSectionReader(r, 0, size), opts)
+}
+
+// DecodeFile decodes the file at path. On Linux the file is memory mapped
+// and uncompressed sections are decoded directly from the mapping, the
+// resource does not refer to the mapping once DecodeFile returns
+func DecodeFile(path string) (Resource, string, error) {
+	return DecodeFileWithOptions(path, Options{})
+}
+
+// DecodeFileWithOptions is the same as DecodeFile using opts instead of the
+// defaults, opts.Filename is set to path if it is empty
+func DecodeFileWithOptions(path string, opts Options) (Resource, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Resource{}, "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return Resource{}, "", err
+	}
+	b, unmap, err := mapFile(f, fi.Size())
+	if err != nil {
+		return Resource{}, "", err
+	}
+	defer unmap()
+
+	if opts.Filename == "" {
+		opts.Filename = path
+	}
+	return DecodeWithOptions(NewSliceReader(b), opts)
+}
+
+// An encoder holds a format's name and how to encode it.
+type encoder struct {
+	name   string
+	encode func(io.Writer, *Resource) error
+}
+
+var atomicEncoders atomic.Value
+
+// RegisterEncoder registers a format for use by Encode.
+// Name is the name of the format, like "lsf" or "lsx", it is also the file
+// extension used for the format.
+// Encode is the function that encodes a resource.
+func RegisterEncoder(name string, encode func(io.Writer, *Resource) error) {
+	formatsMu.Lock()
+	encoders, _ := atomicEncoders.Load().([]encoder)
+	atomicEncoders.Store(append(encoders, encoder{name, encode}))
+	formatsMu.Unlock()
+}
+
+// Encode writes res to w using the encoder registered as name
+func Encode(w io.Writer, res *Resource, name string) error {
+	encoders, _ := atomicEncoders.Load().([]encoder)
+	for _, e := range encoders {
+		if e.name == name {
+			return e.encode(w, res)
 		}
 	}
-	return false
+	return ErrFormat
+}
+
+// Encoders returns the names of the registered encoders in registration order
+func Encoders() []string {
+	var (
+		encoders, _ = atomicEncoders.Load().([]encoder)
+		names       = make([]string, 0, len(encoders))
+	)
+	for _, e := range encoders {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// SupportedFormat reports whether signature, the start of a file, is in a
+// registered format. Text formats need up to SniffLen bytes to be recognised
+func SupportedFormat(signature []byte) bool {
+	_, c := best(signature, "")
+	return c > NoMatch
 }
@@ -0,0 +1,295 @@
+package lsgo_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+	_ "git.narnian.us/lordwelch/lsgo/lsb"
+	_ "git.narnian.us/lordwelch/lsgo/lsf"
+	_ "git.narnian.us/lordwelch/lsgo/lsj"
+	_ "git.narnian.us/lordwelch/lsgo/lsx"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestEncodeRoundTrip encodes a resource with every DataType in each format
+// and checks that decoding it gives the same attributes
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		major  uint32
+		// types the format can't store
+		skip []lsgo.DataType
+		// translated string versions are not stored by binary files
+		// before BG3
+		noVersion bool
+		// TranslatedFSString versions are not stored
+		noFSVersion bool
+	}{
+		// LSB has no encoding for wide strings
+		{format: "lsb", major: 4, skip: []lsgo.DataType{lsgo.DTWString, lsgo.DTLSWString}},
+		{format: "lsb", major: 3, skip: []lsgo.DataType{lsgo.DTWString, lsgo.DTLSWString}, noVersion: true, noFSVersion: true},
+		{format: "lsf", major: 4},
+		{format: "lsf", major: 3, noVersion: true, noFSVersion: true},
+		{format: "lsj", major: 4, noFSVersion: true},
+		{format: "lsx", major: 4, noFSVersion: true},
+		{format: "lsx", major: 3, noFSVersion: true},
+	}
+	for _, tt := range tests {
+		for _, mode := range []lsgo.GUIDMode{lsgo.GUIDStandard, lsgo.GUIDByteSwapped} {
+			res := fixture.AllTypes(tt.major, mode)
+			root := res.Regions[0]
+			var attrs, want []lsgo.NodeAttribute
+		next:
+			for _, attr := range root.Attributes {
+				for _, dt := range tt.skip {
+					if attr.Type == dt {
+						continue next
+					}
+				}
+				attrs = append(attrs, attr)
+				switch v := attr.Value.(type) {
+				case lsgo.TranslatedString:
+					if tt.noVersion {
+						v.Version = 0
+					}
+					attr.Value = v
+				case lsgo.TranslatedFSString:
+					if tt.noFSVersion {
+						attr.Value = withoutVersions(v)
+					}
+				}
+				want = append(want, attr)
+			}
+			root.Attributes = attrs
+
+			name := fmt.Sprintf("%s/v%d/mode%d", tt.format, tt.major, mode)
+			t.Run(name, func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				if err := lsgo.Encode(buf, res, tt.format); err != nil {
+					t.Fatal(err)
+				}
+				got, format, err := lsgo.Decode(bytes.NewReader(buf.Bytes()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if format != tt.format {
+					t.Errorf("decoded as %s", format)
+				}
+				if len(got.Regions) != 1 {
+					t.Fatalf("got %d regions, want 1", len(got.Regions))
+				}
+				got.SetGUIDMode(mode)
+				checkAttributes(t, got.Regions[0].Attributes, want)
+				if c := got.Regions[0].Children; len(c) != 1 || c[0].Name != "child" {
+					t.Errorf("Children = %v, want child", c)
+				}
+			})
+		}
+	}
+}
+
+// withoutVersions returns tfs with the versions of it and its arguments set to 0
+func withoutVersions(tfs lsgo.TranslatedFSString) lsgo.TranslatedFSString {
+	tfs.Version = 0
+	args := make([]lsgo.TranslatedFSStringArgument, len(tfs.Arguments))
+	for i, arg := range tfs.Arguments {
+		arg.String = withoutVersions(arg.String)
+		args[i] = arg
+	}
+	tfs.Arguments = args
+	return tfs
+}
+
+// emptyArguments returns tfs with nil argument lists replaced by empty ones,
+// the decoders differ in which they return for a string without arguments
+func emptyArguments(tfs lsgo.TranslatedFSString) lsgo.TranslatedFSString {
+	args := make([]lsgo.TranslatedFSStringArgument, len(tfs.Arguments))
+	for i, arg := range tfs.Arguments {
+		arg.String = emptyArguments(arg.String)
+		args[i] = arg
+	}
+	tfs.Arguments = args
+	return tfs
+}
+
+func checkAttributes(t *testing.T, got, want []lsgo.NodeAttribute) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d attributes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Name != w.Name || g.Type != w.Type {
+			t.Errorf("attribute %d is %s %v, want %s %v", i, g.Name, g.Type, w.Name, w.Type)
+			continue
+		}
+		if m, ok := w.Value.(*lsgo.Mat); ok {
+			if gm, ok := g.Value.(*lsgo.Mat); !ok || !mat.Equal((*mat.Dense)(m), (*mat.Dense)(gm)) {
+				t.Errorf("%s = %v, want %v", w.Name, g.Value, w.Value)
+			}
+			continue
+		}
+		if fs, ok := g.Value.(lsgo.TranslatedFSString); ok {
+			g.Value = emptyArguments(fs)
+		}
+		if !reflect.DeepEqual(g.Value, w.Value) {
+			t.Errorf("%s = %#v, want %#v", w.Name, g.Value, w.Value)
+		}
+	}
+}
+
+// TestDecodeReaderLimit checks that a reader that can't seek is only read up
+// to the section size limit
+func TestDecodeReaderLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := lsgo.Encode(buf, fixture.AllTypes(4, lsgo.GUIDStandard), "lsf"); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(buf.Len())
+
+	// Hide the Seek method of bytes.Reader
+	r := struct{ io.Reader }{bytes.NewReader(buf.Bytes())}
+	_, _, err := lsgo.DecodeReaderWithOptions(r, lsgo.Options{Limits: lsgo.Limits{MaxSectionSize: 16}})
+	if !errors.Is(err, lsgo.ErrLimitExceeded) {
+		t.Errorf("DecodeReaderWithOptions with a limit of 16 returned %v, want ErrLimitExceeded", err)
+	}
+	if n := int64(r.Reader.(*bytes.Reader).Len()); n != size-17 {
+		t.Errorf("%d bytes were read, want 17", size-n)
+	}
+
+	r = struct{ io.Reader }{bytes.NewReader(buf.Bytes())}
+	_, format, err := lsgo.DecodeReaderWithOptions(r, lsgo.Options{Limits: lsgo.Limits{MaxSectionSize: size}})
+	if err != nil || format != "lsf" {
+		t.Errorf("DecodeReaderWithOptions with a limit of %d returned %q, %v", size, format, err)
+	}
+}
+
+// countingReader counts the bytes read from r and hides its Seek method
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// TestDecodeReaderStream checks that text formats are decoded from the
+// reader and that unknown input is rejected after sniffing it
+func TestDecodeReaderStream(t *testing.T) {
+	for _, format := range []string{"lsj", "lsx"} {
+		buf := &bytes.Buffer{}
+		if err := lsgo.Encode(buf, fixture.AllTypes(4, lsgo.GUIDStandard), format); err != nil {
+			t.Fatal(err)
+		}
+		size := int64(buf.Len())
+
+		// The limit is only exceeded if the decoder reads past SniffLen
+		r := &countingReader{r: bytes.NewReader(buf.Bytes())}
+		limit := int64(lsgo.SniffLen + 16)
+		_, got, err := lsgo.DecodeReaderWithOptions(r, lsgo.Options{Limits: lsgo.Limits{MaxSectionSize: limit}})
+		if !errors.Is(err, lsgo.ErrLimitExceeded) || got != format {
+			t.Errorf("%s: DecodeReaderWithOptions with a limit of %d returned %q, %v, want ErrLimitExceeded", format, limit, got, err)
+		}
+		if r.n != limit+1 {
+			t.Errorf("%s: %d bytes were read, want %d", format, r.n, limit+1)
+		}
+
+		r = &countingReader{r: bytes.NewReader(buf.Bytes())}
+		res, got, err := lsgo.DecodeReader(r)
+		if err != nil || got != format {
+			t.Fatalf("%s: DecodeReader returned %q, %v", format, got, err)
+		}
+		if n := len(res.Regions[0].Attributes); n != int(lsgo.DTMax+1) {
+			t.Errorf("%s: decoded %d attributes, want %d", format, n, lsgo.DTMax+1)
+		}
+		if r.n != size {
+			t.Errorf("%s: %d bytes were read, want %d", format, r.n, size)
+		}
+	}
+
+	r := &countingReader{r: io.LimitReader(zeros{}, 1<<20)}
+	if _, _, err := lsgo.DecodeReader(r); err != lsgo.ErrFormat {
+		t.Errorf("DecodeReader of zeros returned %v, want ErrFormat", err)
+	}
+	if r.n > lsgo.SniffLen {
+		t.Errorf("%d bytes of an unknown format were read, want at most %d", r.n, lsgo.SniffLen)
+	}
+}
+
+func TestDecodeAtWithOptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := lsgo.Encode(buf, fixture.Nodes(10), "lsf"); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	_, format, err := lsgo.DecodeAtWithOptions(r, int64(buf.Len()), lsgo.Options{Limits: lsgo.Limits{MaxNodes: 5}})
+	if !errors.Is(err, lsgo.ErrLimitExceeded) || format != "lsf" {
+		t.Errorf("DecodeAtWithOptions with a limit of 5 nodes returned %q, %v, want ErrLimitExceeded", format, err)
+	}
+
+	// An empty entry is only recognised by its name
+	_, format, _ = lsgo.DecodeAtWithOptions(r, 0, lsgo.Options{Filename: "Public/Game/meta.lsx"})
+	if format != "lsx" {
+		t.Errorf("DecodeAtWithOptions of an empty .lsx file was decoded as %q, want lsx", format)
+	}
+	if _, _, err = lsgo.DecodeAt(r, 0); err != lsgo.ErrFormat {
+		t.Errorf("DecodeAt of an empty file returned %v, want ErrFormat", err)
+	}
+}
+
+// BenchmarkDecodeFile compares reading an uncompressed LSF file with 100k
+// nodes into memory and decoding it, as lsconvert used to, with DecodeFile
+func BenchmarkDecodeFile(b *testing.B) {
+	buf := &bytes.Buffer{}
+	err := lsgo.Encode(buf, fixture.Nodes(100000), "lsf")
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "Templates.lsf")
+	if err = ioutil.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("ReadFile", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err = lsgo.Decode(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DecodeFile", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := lsgo.DecodeFile(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
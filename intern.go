@@ -0,0 +1,74 @@
+package lsgo
+
+import (
+	"io"
+	"sync"
+)
+
+// An Interner deduplicates strings across decoded resources. Resources
+// decoded with the same Interner share a single copy of each node name,
+// attribute name and FixedString value, which are repeated in almost every
+// file of a game. It is safe for concurrent use.
+// The zero value is an empty Interner, a nil *Interner returns strings unchanged
+type Interner struct {
+	mu      sync.RWMutex
+	strings map[string]string
+}
+
+// String returns the interned copy of s
+func (in *Interner) String(s string) string {
+	if in == nil {
+		return s
+	}
+	in.mu.RLock()
+	v, ok := in.strings[s]
+	in.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if v, ok = in.strings[s]; ok {
+		return v
+	}
+	if in.strings == nil {
+		in.strings = make(map[string]string)
+	}
+	in.strings[s] = s
+	return s
+}
+
+// Bytes returns the interned copy of b, it only allocates if b has not
+// been interned before
+func (in *Interner) Bytes(b []byte) string {
+	if in == nil {
+		return string(b)
+	}
+	in.mu.RLock()
+	v, ok := in.strings[string(b)]
+	in.mu.RUnlock()
+	if ok {
+		return v
+	}
+	return in.String(string(b))
+}
+
+// Len returns the number of interned strings
+func (in *Interner) Len() int {
+	if in == nil {
+		return 0
+	}
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return len(in.strings)
+}
+
+// ReadCString is the same as the ReadCString function with the result interned
+func (in *Interner) ReadCString(r io.Reader, length int) (string, error) {
+	if in == nil {
+		return ReadCString(r, length)
+	}
+	buf, err := ReadBytes(r, length)
+	return in.Bytes(buf[:clen(buf)]), err
+}
@@ -0,0 +1,106 @@
+// Package fixture has the resources shared by the tests and benchmarks of
+// lsgo and its formats
+package fixture
+
+import (
+	"fmt"
+	"math"
+
+	"git.narnian.us/lordwelch/lsgo"
+
+	"github.com/google/uuid"
+	"gonum.org/v1/gonum/mat"
+)
+
+// UUID is the value of the DTUUID attribute of Values
+var UUID = uuid.MustParse("0d6d0e8b-6d3e-4d1b-9d3c-2a7e5c1e8f00")
+
+// Values returns a value of every DataType as produced by the binary
+// readers. The map and its values are new on every call and may be modified
+func Values() map[lsgo.DataType]interface{} {
+	return map[lsgo.DataType]interface{}{
+		lsgo.DTNone:          nil,
+		lsgo.DTByte:          byte(255),
+		lsgo.DTShort:         int16(-32768),
+		lsgo.DTUShort:        uint16(65535),
+		lsgo.DTInt:           int32(-2147483648),
+		lsgo.DTUInt:          uint32(4294967295),
+		lsgo.DTFloat:         float32(0.1),
+		lsgo.DTDouble:        0.1,
+		lsgo.DTIVec2:         lsgo.Ivec{1, -2},
+		lsgo.DTIVec3:         lsgo.Ivec{1, -2, 3},
+		lsgo.DTIVec4:         lsgo.Ivec{1, -2, 3, math.MaxInt32},
+		lsgo.DTVec2:          lsgo.Vec{0.5, -1},
+		lsgo.DTVec3:          lsgo.Vec{0.5, -1, float64(float32(0.1))},
+		lsgo.DTVec4:          lsgo.Vec{0.5, -1, float64(float32(0.1)), 1e10},
+		lsgo.DTMat2:          (*lsgo.Mat)(mat.NewDense(2, 2, []float64{1, 2, 3, 4})),
+		lsgo.DTMat3:          (*lsgo.Mat)(mat.NewDense(3, 3, []float64{1, 0, 0, 0, 1, 0, 0, 0, 1})),
+		lsgo.DTMat3x4:        (*lsgo.Mat)(mat.NewDense(3, 4, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, float64(float32(0.1))})),
+		lsgo.DTMat4x3:        (*lsgo.Mat)(mat.NewDense(4, 3, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, -0.5})),
+		lsgo.DTMat4:          (*lsgo.Mat)(mat.NewDense(4, 4, []float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0.25, 0.5, 0.75, 1})),
+		lsgo.DTBool:          true,
+		lsgo.DTString:        "string with spaces",
+		lsgo.DTPath:          "Public/Shared/Assets/a.dds",
+		lsgo.DTFixedString:   "FixedString",
+		lsgo.DTLSString:      "LSString",
+		lsgo.DTULongLong:     uint64(math.MaxUint64),
+		lsgo.DTScratchBuffer: []byte{0, 1, 2, 0xff},
+		lsgo.DTLong:          int64(math.MinInt64),
+		lsgo.DTInt8:          int8(-128),
+		lsgo.DTTranslatedString: lsgo.TranslatedString{
+			Version: 2,
+			Handle:  "h0123456789abcdef",
+		},
+		lsgo.DTWString:   "WString",
+		lsgo.DTLSWString: "LSWString",
+		lsgo.DTUUID:      UUID,
+		lsgo.DTInt64:     int64(math.MaxInt64),
+		lsgo.DTTranslatedFSString: lsgo.TranslatedFSString{
+			TranslatedString: lsgo.TranslatedString{Version: 1, Handle: "h1"},
+			Arguments: []lsgo.TranslatedFSStringArgument{{
+				String: lsgo.TranslatedFSString{
+					TranslatedString: lsgo.TranslatedString{Version: 1, Handle: "h2"},
+					Arguments:        []lsgo.TranslatedFSStringArgument{},
+				},
+				Key:   "Key",
+				Value: "Value",
+			}},
+		},
+	}
+}
+
+// AllTypes returns a resource with a region "root" that has an attribute of
+// every DataType, named after the type, and a child node "child"
+func AllTypes(major uint32, mode lsgo.GUIDMode) *lsgo.Resource {
+	values := Values()
+	root := &lsgo.Node{Name: "root", RegionName: "root"}
+	for dt := lsgo.DTNone; dt <= lsgo.DTMax; dt++ {
+		root.Attributes = append(root.Attributes, lsgo.NodeAttribute{Name: dt.String(), Type: dt, Value: values[dt]})
+	}
+	root.Children = []*lsgo.Node{{Name: "child", Parent: root}}
+	return &lsgo.Resource{
+		Metadata: lsgo.LSMetadata{Major: major, Minor: 1, Revision: 2, Build: 3},
+		Regions:  []*lsgo.Node{root},
+		GUIDMode: mode,
+	}
+}
+
+// Nodes returns a resource with a region "Templates" of n "GameObjects"
+// nodes that each have a few attributes of the common types
+func Nodes(n int) *lsgo.Resource {
+	root := &lsgo.Node{Name: "Templates", RegionName: "Templates"}
+	root.Children = make([]*lsgo.Node, n)
+	for i := range root.Children {
+		root.Children[i] = &lsgo.Node{
+			Name:   "GameObjects",
+			Parent: root,
+			Attributes: []lsgo.NodeAttribute{
+				{Name: "MapKey", Type: lsgo.DTFixedString, Value: fmt.Sprintf("%08x-0000-0000-0000-000000000000", i)},
+				{Name: "Name", Type: lsgo.DTLSString, Value: fmt.Sprint("Object", i%100)},
+				{Name: "Level", Type: lsgo.DTInt, Value: int32(i)},
+				{Name: "Position", Type: lsgo.DTVec3, Value: lsgo.Vec{float64(i), 0, -1}},
+			},
+		}
+	}
+	return &lsgo.Resource{Metadata: lsgo.LSMetadata{Major: 4}, Regions: []*lsgo.Node{root}}
+}
@@ -0,0 +1,133 @@
+package lsgo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A Layout describes where every field of a binary file is stored, it is
+// used to reverse engineer new file versions and to compare the output of
+// the writers with files produced by the game
+type Layout struct {
+	Sections []LayoutSection `json:"sections"`
+	Fields   []LayoutField   `json:"fields"`
+}
+
+// A LayoutSection is a contiguous part of a binary file
+type LayoutSection struct {
+	Name string `json:"name"`
+
+	// Position of the section in the file
+	Offset int64 `json:"offset"`
+
+	// Size of the section in the file
+	SizeOnDisk int64 `json:"size_on_disk"`
+
+	// If the section is compressed the offsets of its fields are relative to
+	// the uncompressed data, otherwise they are file offsets
+	Compressed bool `json:"compressed"`
+
+	// Uncompressed contents of the section
+	Data []byte `json:"-"`
+}
+
+// A LayoutField is a single decoded field
+type LayoutField struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+
+	// Offset is a file offset, or an offset in the uncompressed data of a compressed section
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+
+	Value string `json:"value"`
+
+	// Path of the node the field belongs to, separated by "/"
+	Node string `json:"node,omitempty"`
+
+	// Name of the attribute the field belongs to
+	Attribute string `json:"attribute,omitempty"`
+}
+
+// Section returns the section called name
+func (l Layout) Section(name string) (LayoutSection, bool) {
+	for _, s := range l.Sections {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return LayoutSection{}, false
+}
+
+// A LayoutLogger is a log.Logger that collects the fields logged by the
+// decoders. Every event with a member, the number of bytes read and a start
+// position is a field, the part it was logged in is used as its section
+type LayoutLogger struct {
+	mu     sync.Mutex
+	fields []LayoutField
+}
+
+// Log implements log.Logger
+func (l *LayoutLogger) Log(keyvals ...interface{}) error {
+	var (
+		f               LayoutField
+		hasSize, hasPos bool
+	)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		v := keyvals[i+1]
+		switch keyvals[i] {
+		case "part":
+			f.Section = fmt.Sprint(v)
+		case "member":
+			f.Name = fmt.Sprint(v)
+		case "read":
+			f.Size, hasSize = toInt64(v)
+		case "start position":
+			f.Offset, hasPos = toInt64(v)
+		case "value":
+			f.Value = layoutValue(v)
+		}
+	}
+	if f.Name == "" || !hasSize || !hasPos {
+		return nil
+	}
+	l.mu.Lock()
+	l.fields = append(l.fields, f)
+	l.mu.Unlock()
+	return nil
+}
+
+// Fields returns the fields in the order they were logged
+func (l *LayoutLogger) Fields() []LayoutField {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LayoutField(nil), l.fields...)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func layoutValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
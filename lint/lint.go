@@ -0,0 +1,233 @@
+// Package lint checks resources against project rules that a schema can't
+// express, e.g. identifiers that must be unique across every file of a mod
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"git.narnian.us/lordwelch/lsgo"
+)
+
+// Severity is how serious a problem is
+type Severity int
+
+const (
+	// Off disables a rule
+	Off Severity = iota - 1
+	Info
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Off:
+		return "off"
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// ParseSeverity returns the Severity named str, it is the inverse of Severity.String
+func ParseSeverity(str string) (Severity, error) {
+	for s := Off; s <= Error; s++ {
+		if s.String() == str {
+			return s, nil
+		}
+	}
+	return Off, fmt.Errorf("unknown severity %q", str)
+}
+
+// MarshalText encodes s as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a Severity from its name
+func (s *Severity) UnmarshalText(text []byte) error {
+	v, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// A Problem is something a rule found in a file
+type Problem struct {
+	// Rule is the name of the rule that reported the problem
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file"`
+
+	// Path locates the node in the file, it is the same as the loc given by
+	// lsgo.Resource.Walk
+	Path      string `json:"path,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (p Problem) String() string {
+	s := p.File + ":"
+	if p.Path != "" {
+		s += " " + p.Path
+		if p.Attribute != "" {
+			s += "@" + p.Attribute
+		}
+		s += ":"
+	}
+	return s + " " + p.Severity.String() + " " + p.Rule + ": " + p.Message
+}
+
+// A File is a resource to be checked
+type File struct {
+	// Path is the name the problems of the file are reported with
+	Path     string
+	Resource *lsgo.Resource
+
+	// Suppressions are the problems that are not reported for the file,
+	// usually read from the file with ParseSuppressions
+	Suppressions []Suppression
+}
+
+// A Rule checks resources. Rules may keep state between files, e.g. to find
+// identifiers that are used by more than one file, so a Rule should only be
+// used by one Linter
+type Rule interface {
+	// Name is the name the rule is configured and suppressed with
+	Name() string
+
+	// Severity is the severity of the problems of the rule unless the Linter
+	// overrides it
+	Severity() Severity
+
+	// Check calls report for every problem in f. The Rule, Severity and
+	// File of the problem are filled in by the Linter
+	Check(f *File, report func(Problem))
+}
+
+// NodeRule returns a Rule that calls check for every node of a file with the
+// location of the node
+func NodeRule(name string, severity Severity, check func(f *File, loc string, n *lsgo.Node, report func(Problem))) Rule {
+	return nodeRule{name, severity, check}
+}
+
+type nodeRule struct {
+	name     string
+	severity Severity
+	check    func(f *File, loc string, n *lsgo.Node, report func(Problem))
+}
+
+func (r nodeRule) Name() string       { return r.name }
+func (r nodeRule) Severity() Severity { return r.severity }
+
+func (r nodeRule) Check(f *File, report func(Problem)) {
+	f.Resource.Walk(func(_, loc string, n *lsgo.Node) bool {
+		r.check(f, loc, n, report)
+		return true
+	})
+}
+
+// A Linter runs rules over files
+type Linter struct {
+	Rules []Rule
+
+	// Severity overrides the severity of the rules by name, a rule that is
+	// Off is not run
+	Severity map[string]Severity
+}
+
+// Lint returns the problems the rules find in f that are not suppressed
+func (l *Linter) Lint(f *File) []Problem {
+	var problems []Problem
+	for _, rule := range l.Rules {
+		severity, ok := l.Severity[rule.Name()]
+		if !ok {
+			severity = rule.Severity()
+		}
+		if severity == Off {
+			continue
+		}
+		rule.Check(f, func(p Problem) {
+			p.Rule = rule.Name()
+			p.Severity = severity
+			p.File = f.Path
+			for _, s := range f.Suppressions {
+				if s.Matches(p) {
+					return
+				}
+			}
+			problems = append(problems, p)
+		})
+	}
+	return problems
+}
+
+// A Suppression stops a rule from reporting problems in part of a file
+type Suppression struct {
+	// Rule is the name of the rule that is suppressed, "all" suppresses every rule
+	Rule string
+
+	// Path is the location of the node the rule is suppressed in, including
+	// its children. An empty Path suppresses the rule in the whole file
+	Path string
+}
+
+// Matches reports whether s suppresses p
+func (s Suppression) Matches(p Problem) bool {
+	if s.Rule != "all" && s.Rule != p.Rule {
+		return false
+	}
+	return s.Path == "" || p.Path == s.Path || strings.HasPrefix(p.Path, s.Path+"/")
+}
+
+// directive starts an inline suppression
+const directive = "lint:ignore"
+
+// ParseSuppressions returns the suppressions written in src, the contents of
+// a file. A suppression is written as
+//
+//	lint:ignore rule[,rule...] [path]
+//
+// on a line of its own, usually in a comment of an LSX file:
+//
+//	<!-- lint:ignore empty-name Templates/GameObjects[3] -->
+func ParseSuppressions(src []byte) []Suppression {
+	var suppressions []Suppression
+	for {
+		i := bytes.Index(src, []byte(directive))
+		if i < 0 {
+			return suppressions
+		}
+		src = src[i+len(directive):]
+
+		line := src
+		if end := bytes.IndexAny(line, "\r\n"); end >= 0 {
+			line = line[:end]
+		}
+		if end := bytes.Index(line, []byte("-->")); end >= 0 {
+			line = line[:end]
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+		var path string
+		if len(fields) > 1 {
+			path = fields[1]
+		}
+		for _, rule := range strings.Split(fields[0], ",") {
+			if rule != "" {
+				suppressions = append(suppressions, Suppression{Rule: rule, Path: path})
+			}
+		}
+	}
+}
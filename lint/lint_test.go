@@ -0,0 +1,83 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSuppressions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Suppression
+	}{
+		{
+			name: "path",
+			src:  "<!-- lint:ignore empty-name Templates/GameObjects[3] -->",
+			want: []Suppression{{Rule: "empty-name", Path: "Templates/GameObjects[3]"}},
+		},
+		{
+			name: "rules",
+			src:  "<!-- lint:ignore empty-name,float-precision -->",
+			want: []Suppression{{Rule: "empty-name"}, {Rule: "float-precision"}},
+		},
+		{
+			name: "no spaces",
+			src:  "<!--lint:ignore all-->",
+			want: []Suppression{{Rule: "all"}},
+		},
+		{
+			name: "empty rule",
+			src:  "<!-- lint:ignore a,,b Config -->",
+			want: []Suppression{{Rule: "a", Path: "Config"}, {Rule: "b", Path: "Config"}},
+		},
+		{
+			name: "no rule",
+			src:  "<!-- lint:ignore -->\r\n<save>",
+		},
+		{
+			name: "end of line",
+			src:  "<!-- lint:ignore empty-name\r\n<save> -->",
+			want: []Suppression{{Rule: "empty-name"}},
+		},
+		{
+			name: "several",
+			src: "<?xml version=\"1.0\" encoding=\"utf-8\"?>\r\n" +
+				"<!-- lint:ignore duplicate-id -->\r\n" +
+				"<save>\r\n" +
+				"\t<!-- lint:ignore path-backslash Config/root -->\r\n" +
+				"</save>",
+			want: []Suppression{{Rule: "duplicate-id"}, {Rule: "path-backslash", Path: "Config/root"}},
+		},
+		{
+			name: "none",
+			src:  "<!-- lint ignore empty-name -->",
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseSuppressions([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseSuppressions = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	p := Problem{Rule: "empty-name", Path: "Templates/GameObjects[3]/Tags"}
+	tests := []struct {
+		s    Suppression
+		want bool
+	}{
+		{Suppression{Rule: "empty-name"}, true},
+		{Suppression{Rule: "all"}, true},
+		{Suppression{Rule: "float-precision"}, false},
+		{Suppression{Rule: "empty-name", Path: "Templates/GameObjects[3]"}, true},
+		{Suppression{Rule: "empty-name", Path: "Templates/GameObjects[3]/Tags"}, true},
+		{Suppression{Rule: "empty-name", Path: "Templates/GameObjects[30]"}, false},
+		{Suppression{Rule: "empty-name", Path: "Templates/GameObjects"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Matches(p); got != tt.want {
+			t.Errorf("%+v Matches(%s) = %v, want %v", tt.s, p.Path, got, tt.want)
+		}
+	}
+}
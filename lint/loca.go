@@ -0,0 +1,117 @@
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"git.narnian.us/lordwelch/lsgo"
+)
+
+// Loca maps the handles of translated strings to their text
+type Loca map[string]string
+
+// locaSignature starts a binary .loca file
+const locaSignature = "LOCA"
+
+// ReadLoca reads a localization file, either the binary .loca format or the
+// XML contentList format
+func ReadLoca(r io.Reader) (Loca, error) {
+	br := bufio.NewReader(r)
+	signature, err := br.Peek(len(locaSignature))
+	if err == nil && string(signature) == locaSignature {
+		return readLocaBinary(br)
+	}
+	return readLocaXML(br)
+}
+
+// Add adds the strings of other to l, replacing handles that are in both
+func (l Loca) Add(other Loca) {
+	for handle, text := range other {
+		l[handle] = text
+	}
+}
+
+// readLocaXML reads the content elements of an XML contentList
+func readLocaXML(r io.Reader) (Loca, error) {
+	var (
+		l = make(Loca)
+		d = xml.NewDecoder(r)
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "content" {
+			continue
+		}
+		var content struct {
+			Handle string `xml:"contentuid,attr"`
+			Text   string `xml:",chardata"`
+		}
+		err = d.DecodeElement(&content, &start)
+		if err != nil {
+			return nil, err
+		}
+		l[content.Handle] = content.Text
+	}
+}
+
+// readLocaBinary reads a .loca file. The header is the signature, the number
+// of entries and the offset of the texts. Each entry is a 64 byte handle, a
+// 16 bit version and the 32 bit length of its text, the texts follow the
+// entries in the same order
+func readLocaBinary(r io.Reader) (Loca, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sr := lsgo.NewSliceReader(b)
+	if _, err = sr.Next(len(locaSignature)); err != nil {
+		return nil, err
+	}
+	numEntries, err := lsgo.ReadUint32(sr, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+	textsOffset, err := lsgo.ReadUint32(sr, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	const entrySize = 64 + 2 + 4
+	if int64(numEntries)*entrySize > int64(sr.Len()) || int64(textsOffset) > int64(len(b)) {
+		return nil, fmt.Errorf("loca: %d entries do not fit in %d bytes", numEntries, len(b))
+	}
+	var (
+		l     = make(Loca, numEntries)
+		texts = lsgo.NewSliceReader(b[textsOffset:])
+	)
+	for i := uint32(0); i < numEntries; i++ {
+		p, err := sr.Next(entrySize)
+		if err != nil {
+			return nil, err
+		}
+		key := p[:64]
+		if end := bytes.IndexByte(key, 0); end >= 0 {
+			key = key[:end]
+		}
+		handle := string(key)
+		length := binary.LittleEndian.Uint32(p[66:])
+		text, err := lsgo.ReadCString(texts, int(length))
+		if err != nil {
+			return nil, fmt.Errorf("loca: text of %s: %w", handle, err)
+		}
+		l[handle] = text
+	}
+	return l, nil
+}
@@ -0,0 +1,82 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type locaEntry struct {
+	handle, text string
+}
+
+// loca returns a binary .loca file of entries, the texts are null terminated
+func loca(entries ...locaEntry) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(locaSignature)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(entries)))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(12+len(entries)*70))
+	for _, e := range entries {
+		handle := make([]byte, 64)
+		copy(handle, e.handle)
+		buf.Write(handle)
+		_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(e.text)+1))
+	}
+	for _, e := range entries {
+		buf.WriteString(e.text + "\x00")
+	}
+	return buf.Bytes()
+}
+
+func TestReadLocaBinary(t *testing.T) {
+	valid := loca(locaEntry{"h1", "First"}, locaEntry{"h2", "Second"})
+	patch := func(off int, v uint32) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Loca
+		wantErr bool
+	}{
+		{"valid", valid, Loca{"h1": "First", "h2": "Second"}, false},
+		{"empty", loca(), Loca{}, false},
+		{"signature only", []byte(locaSignature), nil, true},
+		{"truncated header", valid[:10], nil, true},
+		{"truncated entries", valid[:12+70+10], nil, true},
+		{"truncated texts", valid[:len(valid)-3], nil, true},
+		{"too many entries", patch(4, 1000), nil, true},
+		{"texts past the end", patch(8, uint32(len(valid)+1)), nil, true},
+		{"text past the end", patch(12+64+2, 1000), nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ReadLoca(bytes.NewReader(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ReadLoca returned %v, want an error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadLoca = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadLocaXML(t *testing.T) {
+	src := `<?xml version="1.0" encoding="utf-8"?>
+<contentList>
+	<content contentuid="h1" version="1">First &amp; last</content>
+	<content contentuid="h2" version="1"></content>
+</contentList>`
+	got, err := ReadLoca(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Loca{"h1": "First & last", "h2": ""}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadLoca = %v, want %v", got, want)
+	}
+}
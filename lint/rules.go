@@ -0,0 +1,245 @@
+package lint
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"gonum.org/v1/gonum/mat"
+)
+
+// DefaultRules returns the rules that need no configuration
+func DefaultRules() []Rule {
+	return []Rule{
+		DuplicateID("MapKey", "UUID"),
+		EmptyName(),
+		PathBackslash(),
+		FloatPrecision(3, 4),
+	}
+}
+
+// duplicateID reports an identifier that is used by more than one node
+type duplicateID struct {
+	attributes map[string]bool
+
+	// seen is the first use of each identifier by attribute name and value
+	seen map[string]map[string]string
+}
+
+// DuplicateID returns a rule that reports attribute values used by more than
+// one node, in the same file or in any file checked before it. The attributes
+// are matched by name, e.g. "MapKey" or "UUID"
+func DuplicateID(attributes ...string) Rule {
+	r := &duplicateID{
+		attributes: make(map[string]bool, len(attributes)),
+		seen:       make(map[string]map[string]string, len(attributes)),
+	}
+	for _, name := range attributes {
+		r.attributes[name] = true
+		r.seen[name] = make(map[string]string)
+	}
+	return r
+}
+
+func (r *duplicateID) Name() string       { return "duplicate-id" }
+func (r *duplicateID) Severity() Severity { return Error }
+
+func (r *duplicateID) Check(f *File, report func(Problem)) {
+	f.Resource.Walk(func(_, loc string, n *lsgo.Node) bool {
+		for _, attr := range n.Attributes {
+			if !r.attributes[attr.Name] {
+				continue
+			}
+			v := attr.String()
+			if v == "" || v == "00000000-0000-0000-0000-000000000000" {
+				continue
+			}
+			where := f.Path + ": " + loc
+			if first, ok := r.seen[attr.Name][v]; ok {
+				report(Problem{Path: loc, Attribute: attr.Name, Message: fmt.Sprintf("%s %s is also used by %s", attr.Name, v, first)})
+				continue
+			}
+			r.seen[attr.Name][v] = where
+		}
+		return true
+	})
+}
+
+// isString reports whether dt is one of the string types
+func isString(dt lsgo.DataType) bool {
+	switch dt {
+	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString, lsgo.DTWString, lsgo.DTLSWString:
+		return true
+	}
+	return false
+}
+
+// EmptyName returns a rule that reports Name attributes that are empty
+func EmptyName() Rule {
+	return NodeRule("empty-name", Warning, func(f *File, loc string, n *lsgo.Node, report func(Problem)) {
+		for _, attr := range n.Attributes {
+			if attr.Name != "Name" || !isString(attr.Type) {
+				continue
+			}
+			if v, ok := attr.Value.(string); ok && strings.TrimSpace(v) == "" {
+				report(Problem{Path: loc, Attribute: attr.Name, Message: "Name is empty"})
+			}
+		}
+	})
+}
+
+// PathBackslash returns a rule that reports path attributes that use
+// backslashes, the game expects forward slashes
+func PathBackslash() Rule {
+	return NodeRule("path-backslash", Warning, func(f *File, loc string, n *lsgo.Node, report func(Problem)) {
+		for _, attr := range n.Attributes {
+			if attr.Type != lsgo.DTPath {
+				continue
+			}
+			if v, ok := attr.Value.(string); ok && strings.Contains(v, `\`) {
+				report(Problem{Path: loc, Attribute: attr.Name, Message: fmt.Sprintf("path %q uses backslashes", v)})
+			}
+		}
+	})
+}
+
+// unknownHandle is the handle of a TranslatedString without text
+const unknownHandle = "ls::TranslatedStringRepository::s_HandleUnknown"
+
+// MissingHandle returns a rule that reports TranslatedString and
+// TranslatedFSString handles that are not in loca
+func MissingHandle(loca Loca) Rule {
+	return NodeRule("missing-handle", Error, func(f *File, loc string, n *lsgo.Node, report func(Problem)) {
+		for _, attr := range n.Attributes {
+			var handles []string
+			switch v := attr.Value.(type) {
+			case lsgo.TranslatedString:
+				handles = append(handles, v.Handle)
+			case lsgo.TranslatedFSString:
+				handles = fsHandles(handles, v)
+			}
+			for _, h := range handles {
+				if h == "" || h == unknownHandle {
+					continue
+				}
+				if _, ok := loca[h]; !ok {
+					report(Problem{Path: loc, Attribute: attr.Name, Message: fmt.Sprintf("handle %s is not in the localization", h)})
+				}
+			}
+		}
+	})
+}
+
+// fsHandles appends the handles of tfs and its arguments to handles
+func fsHandles(handles []string, tfs lsgo.TranslatedFSString) []string {
+	handles = append(handles, tfs.Handle)
+	for _, arg := range tfs.Arguments {
+		handles = fsHandles(handles, arg.String)
+	}
+	return handles
+}
+
+// FloatPrecision returns a rule that reports floats that are within ulps
+// units in the last place of a number with at most decimals decimal places,
+// but not equal to it. They are usually the result of rounding errors in an
+// editor, e.g. 0.99999994 instead of 1
+func FloatPrecision(decimals, ulps int) Rule {
+	return NodeRule("float-precision", Info, func(f *File, loc string, n *lsgo.Node, report func(Problem)) {
+		for _, attr := range n.Attributes {
+			for _, v := range floats(attr) {
+				if r, ok := normalise(v, attr.Type == lsgo.DTDouble, decimals, ulps); !ok {
+					bits := 32
+					if attr.Type == lsgo.DTDouble {
+						bits = 64
+					}
+					report(Problem{
+						Path:      loc,
+						Attribute: attr.Name,
+						Message:   fmt.Sprintf("%s is probably %s", strconv.FormatFloat(v, 'g', -1, bits), strconv.FormatFloat(r, 'g', -1, bits)),
+					})
+				}
+			}
+		}
+	})
+}
+
+// floats returns the floating point values of attr
+func floats(attr lsgo.NodeAttribute) []float64 {
+	switch v := attr.Value.(type) {
+	case float32:
+		return []float64{float64(v)}
+	case float64:
+		return []float64{v}
+	case lsgo.Vec:
+		return v
+	case *lsgo.Mat:
+		return (*mat.Dense)(v).RawMatrix().Data
+	}
+	return nil
+}
+
+// normalise returns the number with the fewest decimal places that v is
+// within ulps of and false, or v and true if there is none or v is already
+// that number. Single precision is used unless double is set
+func normalise(v float64, double bool, decimals, ulps int) (float64, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v, true
+	}
+	for d := 0; d <= decimals; d++ {
+		scale := math.Pow10(d)
+		r := math.Round(v*scale) / scale
+		var dist uint64
+		if double {
+			dist = ulpDistance64(v, r)
+		} else {
+			r = float64(float32(r))
+			dist = uint64(ulpDistance32(float32(v), float32(r)))
+		}
+		if dist == 0 {
+			return v, true
+		}
+		if dist <= uint64(ulps) {
+			return r, false
+		}
+	}
+	return v, true
+}
+
+// ulpDistance32 returns the number of float32 values between a and b
+func ulpDistance32(a, b float32) uint32 {
+	x, y := ordered32(a), ordered32(b)
+	if x > y {
+		return uint32(x - y)
+	}
+	return uint32(y - x)
+}
+
+// ordered32 maps f to an integer that has the same order as the floats
+func ordered32(f float32) int64 {
+	b := int64(math.Float32bits(f))
+	if b&(1<<31) != 0 {
+		return -(b &^ (1 << 31))
+	}
+	return b
+}
+
+// ulpDistance64 returns the number of float64 values between a and b
+func ulpDistance64(a, b float64) uint64 {
+	x, y := ordered64(a), ordered64(b)
+	if x > y {
+		return x - y
+	}
+	return y - x
+}
+
+// ordered64 maps f to an integer that has the same order as the floats,
+// offset so that it is never negative
+func ordered64(f float64) uint64 {
+	b := math.Float64bits(f)
+	if b&(1<<63) != 0 {
+		return 1<<63 - (b &^ (1 << 63))
+	}
+	return 1<<63 + b
+}
@@ -0,0 +1,70 @@
+package lint
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalise(t *testing.T) {
+	// The smallest positive float32
+	denormal := float64(math.Float32frombits(1))
+	// Added at run time, a constant expression would be exactly 0.3
+	tenth, fifth := 0.1, 0.2
+	tests := []struct {
+		v      float64
+		double bool
+		want   float64
+		ok     bool
+	}{
+		{float64(float32(0.99999994)), false, 1, false},
+		{float64(float32(-0.99999994)), false, -1, false},
+		{float64(float32(1.0000001)), false, 1, false},
+		{float64(float32(0.1)), false, float64(float32(0.1)), true},
+		{float64(float32(0.12345)), false, float64(float32(0.12345)), true},
+		{float64(float32(-2.5)), false, -2.5, true},
+		{1, false, 1, true},
+		{0, false, 0, true},
+		{math.Copysign(0, -1), false, math.Copysign(0, -1), true},
+		{denormal, false, 0, false},
+		{-denormal, false, math.Copysign(0, -1), false},
+		{tenth + fifth, true, 0.3, false},
+		{float64(float32(0.99999994)), true, float64(float32(0.99999994)), true},
+		{math.NaN(), false, math.NaN(), true},
+		{math.Inf(-1), false, math.Inf(-1), true},
+	}
+	for _, tt := range tests {
+		got, ok := normalise(tt.v, tt.double, 3, 4)
+		same := got == tt.want && math.Signbit(got) == math.Signbit(tt.want) || math.IsNaN(got) && math.IsNaN(tt.want)
+		if !same || ok != tt.ok {
+			t.Errorf("normalise(%v, %v) = %v, %v, want %v, %v", tt.v, tt.double, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOrdered32(t *testing.T) {
+	denormal := math.Float32frombits(1)
+	tests := []struct {
+		f    float32
+		want int64
+	}{
+		{0, 0},
+		{float32(math.Copysign(0, -1)), 0},
+		{denormal, 1},
+		{-denormal, -1},
+		{1, 0x3f800000},
+		{-1, -0x3f800000},
+		{float32(math.Inf(1)), 0x7f800000},
+		{float32(math.Inf(-1)), -0x7f800000},
+	}
+	for _, tt := range tests {
+		if got := ordered32(tt.f); got != tt.want {
+			t.Errorf("ordered32(%v) = %#x, want %#x", tt.f, got, tt.want)
+		}
+	}
+	if d := ulpDistance32(-denormal, denormal); d != 2 {
+		t.Errorf("ulpDistance32 across 0 = %d, want 2", d)
+	}
+	if d := ulpDistance32(0.99999994, 1); d != 1 {
+		t.Errorf("ulpDistance32(0.99999994, 1) = %d, want 1", d)
+	}
+}
@@ -1,11 +1,13 @@
 package lsb
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 
 	"git.narnian.us/lordwelch/lsgo"
 
@@ -18,21 +20,36 @@ const (
 )
 
 type Header struct {
-	Signature  [4]byte
-	Size       uint32
+	Signature [4]byte
+	Size      uint32
+
+	// 0 for little-endian files, anything else for big-endian (console) files.
+	// Every field after it is stored in this byte order
 	Endianness uint32
 	Unknown    uint32
 	Version    lsgo.LSMetadata
 }
 
+// ByteOrder returns the byte order of the file described by h
+func (h Header) ByteOrder() binary.ByteOrder {
+	if h.Endianness != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
 func (h *Header) Read(r io.ReadSeeker) error {
+	return h.read(r, lsgo.Logger)
+}
+
+func (h *Header) read(r io.ReadSeeker, logger log.Logger) error {
 	var (
 		l   log.Logger
 		pos int64
 		n   int
 		err error
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "header")
+	l = lsgo.With(logger, "component", "LS converter", "file type", "lsb", "part", "header")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	n, err = r.Read(h.Signature[:])
@@ -41,23 +58,31 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	}
 	l.Log("member", "Signature", "read", n, "start position", pos, "value", fmt.Sprintf("%#x", h.Signature[:]))
 	pos += int64(n)
-	err = binary.Read(r, binary.LittleEndian, &h.Size)
-	n = 4
+
+	// The byte order is not known until the endianness has been read,
+	// an endianness of 0 is the same in either order
+	var size, endianness [4]byte
+	_, err = io.ReadFull(r, size[:])
 	if err != nil {
 		return err
 	}
-	l.Log("member", "Size", "read", n, "start position", pos, "value", h.Size)
-	pos += int64(n)
-
-	err = binary.Read(r, binary.LittleEndian, &h.Endianness)
-	n = 4
+	_, err = io.ReadFull(r, endianness[:])
 	if err != nil {
 		return err
 	}
+	h.Endianness = binary.BigEndian.Uint32(endianness[:])
+	order := h.ByteOrder()
+	h.Size = order.Uint32(size[:])
+	h.Endianness = order.Uint32(endianness[:])
+
+	n = 4
+	l.Log("member", "Size", "read", n, "start position", pos, "value", h.Size)
+	pos += int64(n)
+
 	l.Log("member", "Endianness", "read", n, "start position", pos, "value", h.Endianness)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Unknown)
+	h.Unknown, err = lsgo.ReadUint32(r, order)
 	n = 4
 	if err != nil {
 		return err
@@ -65,15 +90,15 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Unknown", "read", n, "start position", pos, "value", h.Unknown)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Version.Timestamp)
-	n = 4
+	h.Version.Timestamp, err = lsgo.ReadUint64(r, order)
 	if err != nil {
 		return err
 	}
+	n = 8
 	l.Log("member", "Version.Timestamp", "read", n, "start position", pos, "value", h.Version.Timestamp)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Version.Major)
+	h.Version.Major, err = lsgo.ReadUint32(r, order)
 	n = 4
 	if err != nil {
 		return err
@@ -81,7 +106,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Version.Major", "read", n, "start position", pos, "value", h.Version.Major)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Version.Minor)
+	h.Version.Minor, err = lsgo.ReadUint32(r, order)
 	n = 4
 	if err != nil {
 		return err
@@ -89,14 +114,15 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Version.Minor", "read", n, "start position", pos, "value", h.Version.Minor)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Version.Revision)
+	h.Version.Revision, err = lsgo.ReadUint32(r, order)
 	n = 4
 	if err != nil {
 		return err
 	}
 	l.Log("member", "Version.Revision", "read", n, "start position", pos, "value", h.Version.Revision)
+	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Version.Build)
+	h.Version.Build, err = lsgo.ReadUint32(r, order)
 	n = 4
 	if err != nil {
 		return err
@@ -109,7 +135,13 @@ func (h *Header) Read(r io.ReadSeeker) error {
 
 type IdentifierDictionary map[int]string
 
+// Read decodes the LSB file in r
 func Read(r io.ReadSeeker) (lsgo.Resource, error) {
+	return ReadWithOptions(r, lsgo.Options{})
+}
+
+// ReadWithOptions decodes the LSB file in r using opts
+func ReadWithOptions(r io.ReadSeeker, opts lsgo.Options) (lsgo.Resource, error) {
 	var (
 		hdr = &Header{}
 		err error
@@ -119,11 +151,11 @@ func Read(r io.ReadSeeker) (lsgo.Resource, error) {
 		l   log.Logger
 		pos int64
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "file")
+	l = opts.With("component", "LS converter", "file type", "lsb", "part", "file")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 	l.Log("member", "header", "start position", pos)
 
-	err = hdr.Read(r)
+	err = hdr.read(r, opts.With())
 	if err != nil {
 		return lsgo.Resource{}, err
 	}
@@ -133,23 +165,41 @@ func Read(r io.ReadSeeker) (lsgo.Resource, error) {
 			Got:      hdr.Signature[:],
 		}
 	}
+	err = opts.Limits.CheckSectionSize(int64(hdr.Size))
+	if err != nil {
+		return lsgo.Resource{}, err
+	}
 
-	pos, _ = r.Seek(0, io.SeekCurrent)
+	// The rest of the file is decoded from memory, region offsets are
+	// relative to the start of the file
+	end, _ := r.Seek(0, io.SeekCurrent)
+	_, err = r.Seek(pos, io.SeekStart)
+	if err != nil {
+		return lsgo.Resource{}, err
+	}
+	data, err := lsgo.ReadAll(r)
+	if err != nil {
+		return lsgo.Resource{}, err
+	}
+	sr := lsgo.NewSliceReader(data)
+	_, _ = sr.Seek(end-pos, io.SeekStart)
+
+	pos, _ = sr.Seek(0, io.SeekCurrent)
 	l.Log("member", "string dictionary", "start position", pos)
-	d, err = ReadLSBDictionary(r, binary.LittleEndian)
+	d, err = ReadLSBDictionary(sr, hdr.ByteOrder(), opts)
 	if err != nil {
 		return lsgo.Resource{}, err
 	}
 
-	pos, _ = r.Seek(0, io.SeekCurrent)
+	pos, _ = sr.Seek(0, io.SeekCurrent)
 	l.Log("member", "Regions", "start position", pos)
 
-	res, err = ReadLSBRegions(r, d, binary.LittleEndian, lsgo.FileVersion(hdr.Version.Major))
+	res, err = ReadLSBRegions(sr, d, hdr.ByteOrder(), lsgo.FileVersion(hdr.Version.Major), opts.GUIDModeFor(hdr.Version), opts)
 	res.Metadata = hdr.Version
 	return res, err
 }
 
-func ReadLSBDictionary(r io.ReadSeeker, endianness binary.ByteOrder) (IdentifierDictionary, error) {
+func ReadLSBDictionary(r io.ReadSeeker, endianness binary.ByteOrder, opts lsgo.Options) (IdentifierDictionary, error) {
 	var (
 		dict   IdentifierDictionary
 		length uint32
@@ -159,10 +209,10 @@ func ReadLSBDictionary(r io.ReadSeeker, endianness binary.ByteOrder) (Identifier
 		pos int64
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "dictionary")
+	l = opts.With("component", "LS converter", "file type", "lsb", "part", "dictionary")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
-	err = binary.Read(r, endianness, &length)
+	length, err = lsgo.ReadUint32(r, endianness)
 	n = 4
 	if err != nil {
 		return nil, err
@@ -177,23 +227,27 @@ func ReadLSBDictionary(r io.ReadSeeker, endianness binary.ByteOrder) (Identifier
 			key          uint32
 			str          string
 		)
-		err = binary.Read(r, endianness, &stringLength)
+		stringLength, err = lsgo.ReadUint32(r, endianness)
 		n = 4
 		if err != nil {
 			return dict, err
 		}
 		l.Log("member", "stringLength", "read", n, "start position", pos, "value", stringLength)
 		pos += int64(n)
+		err = opts.Limits.CheckStringLength(int(stringLength))
+		if err != nil {
+			return dict, err
+		}
 
-		str, err = lsgo.ReadCString(r, int(stringLength))
-		n += int(stringLength)
+		str, err = opts.Interner.ReadCString(r, int(stringLength))
+		n = int(stringLength)
 		if err != nil {
 			return dict, err
 		}
 		l.Log("member", "str", "read", n, "start position", pos, "value", str)
 		pos += int64(n)
 
-		err = binary.Read(r, endianness, &key)
+		key, err = lsgo.ReadUint32(r, endianness)
 		n = 4
 		if err != nil {
 			return dict, err
@@ -205,7 +259,20 @@ func ReadLSBDictionary(r io.ReadSeeker, endianness binary.ByteOrder) (Identifier
 	return dict, nil
 }
 
-func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.ByteOrder, version lsgo.FileVersion) (lsgo.Resource, error) {
+// decoder holds the state of the regions of a single LSB file
+type decoder struct {
+	r          io.ReadSeeker
+	d          IdentifierDictionary
+	endianness binary.ByteOrder
+	version    lsgo.FileVersion
+	guidMode   lsgo.GUIDMode
+	opts       lsgo.Options
+
+	// Nodes and attributes decoded so far, checked against opts.Limits
+	nodes, attributes int
+}
+
+func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.ByteOrder, version lsgo.FileVersion, guidMode lsgo.GUIDMode, opts lsgo.Options) (lsgo.Resource, error) {
 	var (
 		regions []struct {
 			name   string
@@ -218,10 +285,10 @@ func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.B
 		pos int64
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "region")
+	l = opts.With("component", "LS converter", "file type", "lsb", "part", "region")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
-	err = binary.Read(r, endianness, &regionCount)
+	regionCount, err = lsgo.ReadUint32(r, endianness)
 	n = 4
 	if err != nil {
 		return lsgo.Resource{}, err
@@ -238,7 +305,7 @@ func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.B
 			key uint32
 			ok  bool
 		)
-		err = binary.Read(r, endianness, &key)
+		key, err = lsgo.ReadUint32(r, endianness)
 		n = 4
 		if err != nil {
 			return lsgo.Resource{}, err
@@ -248,7 +315,7 @@ func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.B
 		if regions[i].name, ok = d[int(key)]; !ok {
 			return lsgo.Resource{}, lsgo.ErrInvalidNameKey
 		}
-		err = binary.Read(r, endianness, &regions[i].offset)
+		regions[i].offset, err = lsgo.ReadUint32(r, endianness)
 		n = 4
 		if err != nil {
 			return lsgo.Resource{}, err
@@ -260,11 +327,20 @@ func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.B
 		return regions[i].offset < regions[j].offset
 	})
 	res := lsgo.Resource{
-		Regions: make([]*lsgo.Node, 0, regionCount),
+		Regions:  make([]*lsgo.Node, 0, regionCount),
+		GUIDMode: guidMode,
+	}
+	dec := &decoder{
+		r:          r,
+		d:          d,
+		endianness: endianness,
+		version:    version,
+		guidMode:   guidMode,
+		opts:       opts,
 	}
 	for _, re := range regions {
 		var node *lsgo.Node
-		node, err = readLSBNode(r, d, endianness, version, re.offset)
+		node, err = dec.readNode(re.offset)
 		if err != nil {
 			return res, err
 		}
@@ -274,8 +350,13 @@ func ReadLSBRegions(r io.ReadSeeker, d IdentifierDictionary, endianness binary.B
 	return res, nil
 }
 
-func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.ByteOrder, version lsgo.FileVersion, offset uint32) (*lsgo.Node, error) {
+// readNode decodes a node and its children, offset is the position of a region or 0
+func (dec *decoder) readNode(offset uint32) (*lsgo.Node, error) {
 	var (
+		r          = dec.r
+		d          = dec.d
+		endianness = dec.endianness
+
 		key        uint32
 		attrCount  uint32
 		childCount uint32
@@ -287,14 +368,21 @@ func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.Byte
 		pos int64
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "node")
+	l = dec.opts.With("component", "LS converter", "file type", "lsb", "part", "node")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	if pos != int64(offset) && offset != 0 {
-		panic("shit")
+		if dec.opts.Strict {
+			return nil, fmt.Errorf("%w: region starts at %d, expected %d", lsgo.ErrSectionSize, pos, offset)
+		}
+		l.Log("member", "region", "msg", "seeking to correct offset", "current", pos, "wanted", offset)
+		pos, err = r.Seek(int64(offset), io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = binary.Read(r, endianness, &key)
+	key, err = lsgo.ReadUint32(r, endianness)
 	n = 4
 	if err != nil {
 		return nil, err
@@ -306,7 +394,7 @@ func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.Byte
 		return nil, errors.New("node id key is invalid")
 	}
 
-	err = binary.Read(r, endianness, &attrCount)
+	attrCount, err = lsgo.ReadUint32(r, endianness)
 	n = 4
 	if err != nil {
 		return node, err
@@ -314,17 +402,34 @@ func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.Byte
 	l.Log("member", "attrCount", "read", n, "start position", pos, "value", attrCount)
 	pos += int64(n)
 
-	err = binary.Read(r, endianness, &childCount)
+	childCount, err = lsgo.ReadUint32(r, endianness)
 	n = 4
 	if err != nil {
 		return node, err
 	}
 	l.Log("member", "childCount", "read", n, "start position", pos, "value", childCount)
 
+	dec.attributes += int(attrCount)
+	err = dec.opts.Limits.CheckAttributes(dec.attributes)
+	if err != nil {
+		return node, err
+	}
+	dec.nodes++
+	err = dec.opts.Limits.CheckNodes(dec.nodes)
+	if err != nil {
+		return node, err
+	}
+	// The children are counted when they are read, checking them here as well
+	// keeps a bogus child count from being allocated
+	err = dec.opts.Limits.CheckNodes(dec.nodes + int(childCount))
+	if err != nil {
+		return node, err
+	}
+
 	node.Attributes = make([]lsgo.NodeAttribute, int(attrCount))
 
 	for i := range node.Attributes {
-		node.Attributes[i], err = readLSBAttribute(r, d, endianness, version)
+		node.Attributes[i], err = dec.readAttribute(l)
 		if err != nil {
 			return node, err
 		}
@@ -332,7 +437,7 @@ func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.Byte
 
 	node.Children = make([]*lsgo.Node, int(childCount))
 	for i := range node.Children {
-		node.Children[i], err = readLSBNode(r, d, endianness, version, 0)
+		node.Children[i], err = dec.readNode(0)
 		if err != nil {
 			return node, err
 		}
@@ -340,30 +445,45 @@ func readLSBNode(r io.ReadSeeker, d IdentifierDictionary, endianness binary.Byte
 	return node, nil
 }
 
-func readLSBAttribute(r io.ReadSeeker, d IdentifierDictionary, endianness binary.ByteOrder, version lsgo.FileVersion) (lsgo.NodeAttribute, error) {
+// readAttribute decodes an attribute of a node, l is the logger of the node
+func (dec *decoder) readAttribute(l log.Logger) (lsgo.NodeAttribute, error) {
 	var (
+		r          = dec.r
+		endianness = dec.endianness
+
 		key      uint32
 		name     string
 		attrType uint32
 		attr     lsgo.NodeAttribute
 		err      error
 		ok       bool
+
+		pos int64
+		n   int
 	)
-	err = binary.Read(r, endianness, &key)
+	pos, _ = r.Seek(0, io.SeekCurrent)
+
+	key, err = lsgo.ReadUint32(r, endianness)
+	n = 4
 	if err != nil {
 		return attr, err
 	}
-	if name, ok = d[int(key)]; !ok {
+	l.Log("member", "attribute.key", "read", n, "start position", pos, "value", dec.d[int(key)], "key", key)
+	pos += int64(n)
+	if name, ok = dec.d[int(key)]; !ok {
 		return attr, lsgo.ErrInvalidNameKey
 	}
-	err = binary.Read(r, endianness, &attrType)
+
+	attrType, err = lsgo.ReadUint32(r, endianness)
+	n = 4
 	if err != nil {
 		return attr, err
 	}
-	return ReadLSBAttr(r, name, lsgo.DataType(attrType), endianness, version)
+	l.Log("member", "attribute.type", "read", n, "start position", pos, "value", lsgo.DataType(attrType))
+	return ReadLSBAttr(r, name, lsgo.DataType(attrType), endianness, dec.version, dec.guidMode, dec.opts)
 }
 
-func ReadLSBAttr(r io.ReadSeeker, name string, dt lsgo.DataType, endianness binary.ByteOrder, version lsgo.FileVersion) (lsgo.NodeAttribute, error) {
+func ReadLSBAttr(r io.ReadSeeker, name string, dt lsgo.DataType, endianness binary.ByteOrder, version lsgo.FileVersion, guidMode lsgo.GUIDMode, opts lsgo.Options) (lsgo.NodeAttribute, error) {
 	// LSF and LSB serialize the buffer types differently, so specialized
 	// code is added to the LSB and LSf serializers, and the common code is
 	// available in BinUtils.ReadAttribute()
@@ -378,20 +498,29 @@ func ReadLSBAttr(r io.ReadSeeker, name string, dt lsgo.DataType, endianness bina
 		l   log.Logger
 		pos int64
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsb", "part", "attribute")
+	l = opts.With("component", "LS converter", "file type", "lsb", "part", "attribute")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	switch dt {
 	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString: // DTLSWString:
 		var v string
-		err = binary.Read(r, endianness, &length)
+		length, err = lsgo.ReadUint32(r, endianness)
+		if err != nil {
+			return attr, err
+		}
+		err = opts.Limits.CheckStringLength(int(length))
 		if err != nil {
 			return attr, err
 		}
-		v, err = lsgo.ReadCString(r, int(length))
+		if dt == lsgo.DTFixedString {
+			v, err = opts.Interner.ReadCString(r, int(length))
+		} else {
+			v, err = lsgo.ReadCString(r, int(length))
+		}
 		attr.Value = v
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		end, _ := r.Seek(0, io.SeekCurrent)
+		l.Log("member", name, "read", end-pos, "start position", pos, "value", attr.Value)
 
 		return attr, err
 
@@ -400,40 +529,371 @@ func ReadLSBAttr(r io.ReadSeeker, name string, dt lsgo.DataType, endianness bina
 
 	case lsgo.DTTranslatedString:
 		var v lsgo.TranslatedString
-		v, err = lsgo.ReadTranslatedString(r, version, 0)
+		v, err = lsgo.ReadTranslatedString(r, endianness, version, 0)
 		attr.Value = v
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		end, _ := r.Seek(0, io.SeekCurrent)
+		l.Log("member", name, "read", end-pos, "start position", pos, "value", attr.Value)
 
 		return attr, err
 
 	case lsgo.DTTranslatedFSString:
-		panic("Not implemented")
 		var v lsgo.TranslatedFSString
-		// v, err = ReadTranslatedFSString(r, Version)
+		v, err = lsgo.ReadTranslatedFSString(r, endianness, version)
 		attr.Value = v
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		end, _ := r.Seek(0, io.SeekCurrent)
+		l.Log("member", name, "read", end-pos, "start position", pos, "value", attr.Value)
 
 		return attr, err
 
 	case lsgo.DTScratchBuffer:
-		panic("Not implemented")
-
+		length, err = lsgo.ReadUint32(r, endianness)
+		if err != nil {
+			return attr, err
+		}
+		err = opts.Limits.CheckStringLength(int(length))
+		if err != nil {
+			return attr, err
+		}
 		v := make([]byte, length)
-		_, err = r.Read(v)
+		_, err = io.ReadFull(r, v)
 		attr.Value = v
 
-		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
+		end, _ := r.Seek(0, io.SeekCurrent)
+		l.Log("member", name, "read", end-pos, "start position", pos, "value", attr.Value)
 
 		return attr, err
 
 	default:
-		return lsgo.ReadAttribute(r, name, dt, uint(length), l)
+		return lsgo.ReadAttribute(r, name, dt, uint(length), endianness, guidMode, l)
+	}
+}
+
+// ReadLayout decodes the LSB file in r and returns the position and value of
+// every field. The fields are the ones logged by the decoder
+func ReadLayout(r io.ReadSeeker) (lsgo.Layout, error) {
+	var (
+		layout lsgo.Layout
+		c      = &lsgo.LayoutLogger{}
+	)
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return layout, err
+	}
+	_, err = ReadWithOptions(r, lsgo.Options{Logger: c})
+	if err != nil {
+		return layout, err
+	}
+	_, err = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return layout, err
+	}
+	data, err := lsgo.ReadAll(r)
+	if err != nil {
+		return layout, err
+	}
+
+	layout.Fields = layoutFields(c.Fields(), start)
+	end := start
+	for _, name := range []string{"header", "dictionary", "regions", "nodes"} {
+		sec := lsgo.LayoutSection{Name: name, Offset: end}
+		for _, f := range layout.Fields {
+			if f.Section == name && f.Offset+f.Size > end {
+				end = f.Offset + f.Size
+			}
+		}
+		sec.SizeOnDisk = end - sec.Offset
+		sec.Data = data[sec.Offset-start : end-start]
+		layout.Sections = append(layout.Sections, sec)
+	}
+	return layout, nil
+}
+
+// layoutFields names the fields logged by ReadLayout after the section and
+// the node they were read from, start is the position of the file in r
+func layoutFields(logged []lsgo.LayoutField, start int64) []lsgo.LayoutField {
+	type parent struct {
+		path     string
+		children int64
+	}
+	var (
+		fields = make([]lsgo.LayoutField, 0, len(logged))
+		count  = make(map[string]int)
+
+		// parents of the current node that have children left
+		parents    []parent
+		node, attr string
+		dt         lsgo.DataType
+	)
+	for _, f := range logged {
+		// Everything after the header is decoded from memory
+		if f.Section != "header" {
+			f.Offset += start
+		}
+		switch f.Section {
+		case "header":
+		case "dictionary":
+			if f.Name != "length" {
+				count[f.Name]++
+				f.Name = fmt.Sprintf("%s[%d]", f.Name, count[f.Name]-1)
+			}
+		case "region":
+			f.Section = "regions"
+			if f.Name != "regionCount" {
+				if f.Name == "key" {
+					node = f.Value
+				}
+				count["region."+f.Name]++
+				f.Name = fmt.Sprintf("region[%d].%s", count["region."+f.Name]-1, f.Name)
+				f.Node = node
+			}
+		case "node":
+			f.Section = "nodes"
+			switch f.Name {
+			case "key":
+				for len(parents) > 0 && parents[len(parents)-1].children == 0 {
+					parents = parents[:len(parents)-1]
+				}
+				node, attr = f.Value, ""
+				if len(parents) > 0 {
+					parents[len(parents)-1].children--
+					node = parents[len(parents)-1].path + "/" + node
+				}
+			case "childCount":
+				children, _ := strconv.ParseInt(f.Value, 10, 64)
+				parents = append(parents, parent{path: node, children: children})
+			case "attribute.key":
+				attr = f.Value
+			case "attribute.type":
+				dt, _ = lsgo.ParseDataType(f.Value)
+			}
+			f.Node, f.Attribute = node, attr
+		case "attribute":
+			f.Section = "nodes"
+			f.Name = "attribute.value"
+			f.Node, f.Attribute = node, attr
+			// Buffers are logged as is, format them as they are in text files
+			if dt == lsgo.DTScratchBuffer {
+				f.Value = lsgo.NodeAttribute{Type: dt, Value: []byte(f.Value)}.String()
+			}
+		default:
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// headerSize is the size of Header when it is written
+const headerSize = 40
+
+// Write writes h to w
+func (h *Header) Write(w io.Writer) error {
+	order := h.ByteOrder()
+	for _, v := range []interface{}{h.Signature, h.Size, h.Endianness, h.Unknown, h.Version.Timestamp, h.Version.Major, h.Version.Minor, h.Version.Revision, h.Version.Build} {
+		err := binary.Write(w, order, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An Encoder writes a Resource as LSB to an output stream.
+// LSB files have no node keys, they are not written
+type Encoder struct {
+	// ByteOrder is the byte order to write, little-endian is used if it is nil
+	ByteOrder binary.ByteOrder
+
+	w     io.Writer
+	order binary.ByteOrder
+	keys  map[string]uint32
+	names []string
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the LSB encoding of res to the stream.
+func (e *Encoder) Encode(res *lsgo.Resource) error {
+	var (
+		hdr   = Header{Version: res.Metadata}
+		dict  bytes.Buffer
+		nodes bytes.Buffer
+		table bytes.Buffer
+		err   error
+
+		version = lsgo.FileVersion(res.Metadata.Major)
+		offsets = make([]int, len(res.Regions))
+	)
+	e.order = e.ByteOrder
+	if e.order == nil {
+		e.order = binary.LittleEndian
+	}
+	e.keys = make(map[string]uint32)
+	e.names = e.names[:0]
+
+	copy(hdr.Signature[:], PreBG3Signature)
+	if res.Metadata.Major >= 4 {
+		copy(hdr.Signature[:], Signature)
+	}
+	if e.order == binary.BigEndian {
+		hdr.Endianness = 1
+	}
+
+	for i, region := range res.Regions {
+		offsets[i] = nodes.Len()
+		err = e.encodeNode(&nodes, region, version, res.GUIDMode)
+		if err != nil {
+			return err
+		}
+	}
+	for _, region := range res.Regions {
+		e.key(region.RegionName)
+	}
+
+	err = binary.Write(&dict, e.order, uint32(len(e.names)))
+	if err != nil {
+		return err
+	}
+	for i, name := range e.names {
+		err = e.writeString(&dict, name)
+		if err != nil {
+			return err
+		}
+		err = binary.Write(&dict, e.order, uint32(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Region offsets are from the start of the file
+	start := headerSize + dict.Len() + 4 + 8*len(res.Regions)
+	err = binary.Write(&table, e.order, uint32(len(res.Regions)))
+	if err != nil {
+		return err
+	}
+	for i, region := range res.Regions {
+		err = binary.Write(&table, e.order, []uint32{e.key(region.RegionName), uint32(start + offsets[i])})
+		if err != nil {
+			return err
+		}
+	}
+
+	hdr.Size = uint32(start + nodes.Len())
+	err = hdr.Write(e.w)
+	if err != nil {
+		return err
+	}
+	for _, b := range []*bytes.Buffer{&dict, &table, &nodes} {
+		_, err = b.WriteTo(e.w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key returns the dictionary key of name, adding it if needed
+func (e *Encoder) key(name string) uint32 {
+	if key, ok := e.keys[name]; ok {
+		return key
+	}
+	key := uint32(len(e.names))
+	e.keys[name] = key
+	e.names = append(e.names, name)
+	return key
+}
+
+func (e *Encoder) writeString(w io.Writer, s string) error {
+	err := binary.Write(w, e.order, uint32(len(s)+1))
+	if err != nil {
+		return err
+	}
+	return lsgo.WriteCString(w, s)
+}
+
+func (e *Encoder) encodeNode(w io.Writer, n *lsgo.Node, version lsgo.FileVersion, guidMode lsgo.GUIDMode) error {
+	err := binary.Write(w, e.order, []uint32{e.key(n.Name), uint32(len(n.Attributes)), uint32(len(n.Children))})
+	if err != nil {
+		return err
+	}
+	for _, attr := range n.Attributes {
+		err = binary.Write(w, e.order, []uint32{e.key(attr.Name), uint32(attr.Type)})
+		if err != nil {
+			return err
+		}
+		err = e.encodeAttribute(w, attr, version, guidMode)
+		if err != nil {
+			return err
+		}
+	}
+	for _, child := range n.Children {
+		err = e.encodeNode(w, child, version, guidMode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeAttribute writes the value of attr, it is the inverse of ReadLSBAttr
+func (e *Encoder) encodeAttribute(w io.Writer, attr lsgo.NodeAttribute, version lsgo.FileVersion, guidMode lsgo.GUIDMode) error {
+	switch attr.Type {
+	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString:
+		v, ok := attr.Value.(string)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return e.writeString(w, v)
+
+	case lsgo.DTWString:
+		return fmt.Errorf("attribute %s: %v is not supported in LSB files", attr.Name, attr.Type)
+
+	case lsgo.DTTranslatedString:
+		v, ok := attr.Value.(lsgo.TranslatedString)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return lsgo.WriteTranslatedString(w, v, e.order, version, 0)
+
+	case lsgo.DTTranslatedFSString:
+		v, ok := attr.Value.(lsgo.TranslatedFSString)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return lsgo.WriteTranslatedFSString(w, v, e.order, version)
+
+	case lsgo.DTScratchBuffer:
+		v, ok := attr.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		err := binary.Write(w, e.order, uint32(len(v)))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(v)
+		return err
+
+	default:
+		return lsgo.WriteAttribute(w, attr, e.order, guidMode)
 	}
 }
 
 func init() {
-	lsgo.RegisterFormat("lsb", Signature, Read)
-	lsgo.RegisterFormat("lsb", PreBG3Signature, Read)
+	for _, magic := range []string{Signature, PreBG3Signature} {
+		lsgo.Register(lsgo.Format{
+			Name:       "lsb",
+			Magic:      magic,
+			Extensions: []string{".lsb", ".lsbs", ".lsbc"},
+			Decode:     ReadWithOptions,
+		})
+	}
+	lsgo.RegisterEncoder("lsb", func(w io.Writer, res *lsgo.Resource) error {
+		return NewEncoder(w).Encode(res)
+	})
 }
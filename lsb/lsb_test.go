@@ -0,0 +1,250 @@
+package lsb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+)
+
+// lsbBuilder writes an LSB file by hand in either byte order, independently
+// of the Encoder
+type lsbBuilder struct {
+	order binary.ByteOrder
+	buf   bytes.Buffer
+}
+
+func (b *lsbBuilder) u32(v uint32) {
+	_ = binary.Write(&b.buf, b.order, v)
+}
+
+func (b *lsbBuilder) write(v interface{}) {
+	_ = binary.Write(&b.buf, b.order, v)
+}
+
+// str writes a string prefixed by its length including the terminating null
+func (b *lsbBuilder) str(s string) {
+	b.u32(uint32(len(s) + 1))
+	b.buf.WriteString(s + "\x00")
+}
+
+func (b *lsbBuilder) attr(key uint32, dt lsgo.DataType) {
+	b.u32(key)
+	b.u32(uint32(dt))
+}
+
+// buildLSB returns a D:OS 2 era LSB file with a single region "Config" with
+// one node "root" that has an attribute of each of the types checked by
+// TestReadByteOrder and a child node "child"
+func buildLSB(order binary.ByteOrder) []byte {
+	// In the order the Encoder assigns the keys in
+	names := []string{"root", "Int", "UInt", "Short", "Int64", "Float", "Double", "Vector", "UUID", "Handle", "Name", "Format", "Buffer", "child", "Config"}
+	b := &lsbBuilder{order: order}
+
+	// Header, the size is filled in at the end
+	b.buf.WriteString(PreBG3Signature)
+	b.u32(0)
+	if order == binary.BigEndian {
+		b.u32(1)
+	} else {
+		b.u32(0)
+	}
+	b.u32(0)
+	b.write(uint64(0x0123456789abcdef))
+	b.write([]uint32{3, 1, 2, 3})
+
+	b.u32(uint32(len(names)))
+	for i, name := range names {
+		b.str(name)
+		b.u32(uint32(i))
+	}
+
+	b.u32(1)
+	b.u32(14)
+	b.u32(uint32(b.buf.Len() + 4))
+
+	// root
+	b.write([]uint32{0, 12, 1})
+	b.attr(1, lsgo.DTInt)
+	b.write(int32(-123456))
+	b.attr(2, lsgo.DTUInt)
+	b.write(uint32(0xdeadbeef))
+	b.attr(3, lsgo.DTShort)
+	b.write(int16(-2))
+	b.attr(4, lsgo.DTInt64)
+	b.write(int64(math.MinInt64 + 1))
+	b.attr(5, lsgo.DTFloat)
+	b.write(float32(1.5))
+	b.attr(6, lsgo.DTDouble)
+	b.write(-0.1)
+	b.attr(7, lsgo.DTVec3)
+	b.write([]float32{1, -2.5, 1e10})
+	b.attr(8, lsgo.DTUUID)
+	// The first three groups are integers in the byte order of the file
+	u := make([]byte, 16)
+	order.PutUint32(u, binary.BigEndian.Uint32(fixture.UUID[0:]))
+	order.PutUint16(u[4:], binary.BigEndian.Uint16(fixture.UUID[4:]))
+	order.PutUint16(u[6:], binary.BigEndian.Uint16(fixture.UUID[6:]))
+	copy(u[8:], fixture.UUID[8:])
+	b.buf.Write(u)
+	b.attr(9, lsgo.DTTranslatedString)
+	b.str("Translated text")
+	b.str("h5a8f1b2cg0c1dg4e3dgb7a6g9f8e7d6c5b4a")
+	b.attr(10, lsgo.DTLSString)
+	b.str("Näme")
+	b.attr(11, lsgo.DTTranslatedFSString)
+	b.str("[1] text")
+	b.str("h1")
+	b.u32(1)
+	b.str("Key")
+	b.str("")
+	b.str("h2")
+	b.u32(0)
+	b.str("Argument")
+	b.attr(12, lsgo.DTScratchBuffer)
+	b.u32(3)
+	b.buf.Write([]byte{1, 2, 0xff})
+
+	// child
+	b.write([]uint32{13, 0, 0})
+
+	p := b.buf.Bytes()
+	order.PutUint32(p[4:], uint32(len(p)))
+	return p
+}
+
+func TestReadByteOrder(t *testing.T) {
+	want := []lsgo.NodeAttribute{
+		{Name: "Int", Type: lsgo.DTInt, Value: int32(-123456)},
+		{Name: "UInt", Type: lsgo.DTUInt, Value: uint32(0xdeadbeef)},
+		{Name: "Short", Type: lsgo.DTShort, Value: int16(-2)},
+		{Name: "Int64", Type: lsgo.DTInt64, Value: int64(math.MinInt64 + 1)},
+		{Name: "Float", Type: lsgo.DTFloat, Value: float32(1.5)},
+		{Name: "Double", Type: lsgo.DTDouble, Value: -0.1},
+		{Name: "Vector", Type: lsgo.DTVec3, Value: lsgo.Vec{1, -2.5, 1e10}},
+		{Name: "UUID", Type: lsgo.DTUUID, Value: fixture.UUID},
+		{Name: "Handle", Type: lsgo.DTTranslatedString, Value: lsgo.TranslatedString{Value: "Translated text", Handle: "h5a8f1b2cg0c1dg4e3dgb7a6g9f8e7d6c5b4a"}},
+		{Name: "Name", Type: lsgo.DTLSString, Value: "Näme"},
+		{Name: "Format", Type: lsgo.DTTranslatedFSString, Value: lsgo.TranslatedFSString{
+			TranslatedString: lsgo.TranslatedString{Value: "[1] text", Handle: "h1"},
+			Arguments: []lsgo.TranslatedFSStringArgument{{
+				Key:    "Key",
+				String: lsgo.TranslatedFSString{TranslatedString: lsgo.TranslatedString{Handle: "h2"}, Arguments: []lsgo.TranslatedFSStringArgument{}},
+				Value:  "Argument",
+			}},
+		}},
+		{Name: "Buffer", Type: lsgo.DTScratchBuffer, Value: []byte{1, 2, 0xff}},
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			res, err := Read(bytes.NewReader(buildLSB(order)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantMeta := lsgo.LSMetadata{Timestamp: 0x0123456789abcdef, Major: 3, Minor: 1, Revision: 2, Build: 3}
+			if res.Metadata != wantMeta {
+				t.Errorf("Metadata = %+v, want %+v", res.Metadata, wantMeta)
+			}
+			if len(res.Regions) != 1 {
+				t.Fatalf("got %d regions, want 1", len(res.Regions))
+			}
+			root := res.Regions[0]
+			if root.RegionName != "Config" || root.Name != "root" {
+				t.Errorf("region %q node %q, want Config root", root.RegionName, root.Name)
+			}
+			if !reflect.DeepEqual(root.Attributes, want) {
+				t.Errorf("Attributes =\n%#v\nwant\n%#v", root.Attributes, want)
+			}
+			if len(root.Children) != 1 || root.Children[0].Name != "child" {
+				t.Errorf("Children = %v, want child", root.Children)
+			}
+		})
+	}
+}
+
+// TestEncodeByteOrder checks that the Encoder writes the same bytes as
+// buildLSB in both byte orders
+func TestEncodeByteOrder(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			want := buildLSB(order)
+			res, err := Read(bytes.NewReader(want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			e := NewEncoder(buf)
+			e.ByteOrder = order
+			if err = e.Encode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("Encode =\n% x\nwant\n% x", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestReadLayout(t *testing.T) {
+	data := buildLSB(binary.BigEndian)
+	layout, err := ReadLayout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		end    int64
+		values = make(map[string]lsgo.LayoutField)
+	)
+	for _, s := range layout.Sections {
+		if s.Offset != end {
+			t.Errorf("section %s starts at %d, want %d", s.Name, s.Offset, end)
+		}
+		end += s.SizeOnDisk
+	}
+	if end != int64(len(data)) {
+		t.Errorf("sections end at %d, want %d", end, len(data))
+	}
+	for _, f := range layout.Fields {
+		if f.Name == "attribute.value" {
+			values[f.Attribute] = f
+		}
+		if f.Name == "key" && f.Value == "child" && f.Node != "root/child" {
+			t.Errorf("child key has node %q", f.Node)
+		}
+	}
+	if f := values["Int"]; f.Size != 4 || !bytes.Equal(data[f.Offset:f.Offset+4], []byte{0xff, 0xfe, 0x1d, 0xc0}) {
+		t.Errorf("Int = %+v", f)
+	}
+	// The length prefix is part of the value
+	if f := values["Buffer"]; f.Size != 7 || f.Value != (lsgo.NodeAttribute{Type: lsgo.DTScratchBuffer, Value: []byte{1, 2, 0xff}}).String() {
+		t.Errorf("Buffer = %+v", f)
+	}
+}
+
+func TestReadMaxNodes(t *testing.T) {
+	// buildLSB has two nodes, root and child
+	tests := []struct {
+		maxNodes int
+		wantErr  bool
+	}{
+		{0, false},
+		{3, false},
+		{2, false},
+		{1, true},
+	}
+	for _, tt := range tests {
+		opts := lsgo.Options{Limits: lsgo.Limits{MaxNodes: tt.maxNodes}}
+		_, err := ReadWithOptions(bytes.NewReader(buildLSB(binary.LittleEndian)), opts)
+		if tt.wantErr != errors.Is(err, lsgo.ErrLimitExceeded) {
+			t.Errorf("MaxNodes %d: got %v, want ErrLimitExceeded %v", tt.maxNodes, err, tt.wantErr)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("MaxNodes %d: %v", tt.maxNodes, err)
+		}
+	}
+}
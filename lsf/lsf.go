@@ -1,10 +1,16 @@
 package lsf
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
 	"git.narnian.us/lordwelch/lsgo"
 
@@ -21,7 +27,8 @@ type Header struct {
 	Version lsgo.FileVersion
 
 	// Possibly version number? (major, minor, rev, build)
-	EngineVersion uint32
+	// 32 bits before VerBG3ExtendedHeader, 64 bits after
+	EngineVersion uint64
 
 	// Total uncompressed size of the string hash table
 	StringsUncompressedSize uint32
@@ -29,6 +36,12 @@ type Header struct {
 	// Compressed size of the string hash table
 	StringsSizeOnDisk uint32
 
+	// Total uncompressed size of the node keys, only present from VerBG3AdditionalBlob
+	KeysUncompressedSize uint32
+
+	// Compressed size of the node keys, only present from VerBG3AdditionalBlob
+	KeysSizeOnDisk uint32
+
 	// Total uncompressed size of the node list
 	NodesUncompressedSize uint32
 
@@ -58,14 +71,28 @@ type Header struct {
 	Extended uint32
 }
 
+func readInt32(r io.Reader) (int32, error) {
+	v, err := lsgo.ReadUint32(r, binary.LittleEndian)
+	return int32(v), err
+}
+
+func readVersion(r io.Reader) (lsgo.FileVersion, error) {
+	v, err := lsgo.ReadUint32(r, binary.LittleEndian)
+	return lsgo.FileVersion(v), err
+}
+
 func (h *Header) Read(r io.ReadSeeker) error {
+	return h.read(r, lsgo.Logger)
+}
+
+func (h *Header) read(r io.ReadSeeker, logger log.Logger) error {
 	var (
 		l   log.Logger
 		pos int64
 		n   int
 		err error
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "header")
+	l = lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "header")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 	n, err = r.Read(h.Signature[:])
 	if err != nil {
@@ -73,7 +100,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	}
 	l.Log("member", "Signature", "read", n, "start position", pos, "value", string(h.Signature[:]))
 	pos += int64(n)
-	err = binary.Read(r, binary.LittleEndian, &h.Version)
+	h.Version, err = readVersion(r)
 	n = 4
 	if err != nil {
 		return err
@@ -81,15 +108,23 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Version", "read", n, "start position", pos, "value", h.Version)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.EngineVersion)
-	n = 4
+	if h.Version >= lsgo.VerBG3ExtendedHeader {
+		h.EngineVersion, err = lsgo.ReadUint64(r, binary.LittleEndian)
+		n = 8
+	} else {
+		var engineVersion uint32
+		engineVersion, err = lsgo.ReadUint32(r, binary.LittleEndian)
+		h.EngineVersion = uint64(engineVersion)
+		n = 4
+	}
 	if err != nil {
 		return err
 	}
-	l.Log("member", "EngineVersion", "read", n, "start position", pos, "value", fmt.Sprintf("%d.%d.%d.%d", (h.EngineVersion&0xf0000000)>>28, (h.EngineVersion&0xf000000)>>24, (h.EngineVersion&0xff0000)>>16, (h.EngineVersion&0xffff)))
+	m := h.Metadata()
+	l.Log("member", "EngineVersion", "read", n, "start position", pos, "value", fmt.Sprintf("%d.%d.%d.%d", m.Major, m.Minor, m.Revision, m.Build))
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.StringsUncompressedSize)
+	h.StringsUncompressedSize, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -97,7 +132,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "StringsUncompressedSize", "read", n, "start position", pos, "value", h.StringsUncompressedSize)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.StringsSizeOnDisk)
+	h.StringsSizeOnDisk, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -105,7 +140,25 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "StringsSizeOnDisk", "read", n, "start position", pos, "value", h.StringsSizeOnDisk)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.NodesUncompressedSize)
+	if h.Version >= lsgo.VerBG3AdditionalBlob {
+		h.KeysUncompressedSize, err = lsgo.ReadUint32(r, binary.LittleEndian)
+		n = 4
+		if err != nil {
+			return err
+		}
+		l.Log("member", "KeysUncompressedSize", "read", n, "start position", pos, "value", h.KeysUncompressedSize)
+		pos += int64(n)
+
+		h.KeysSizeOnDisk, err = lsgo.ReadUint32(r, binary.LittleEndian)
+		n = 4
+		if err != nil {
+			return err
+		}
+		l.Log("member", "KeysSizeOnDisk", "read", n, "start position", pos, "value", h.KeysSizeOnDisk)
+		pos += int64(n)
+	}
+
+	h.NodesUncompressedSize, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -113,7 +166,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "NodesUncompressedSize", "read", n, "start position", pos, "value", h.NodesUncompressedSize)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.NodesSizeOnDisk)
+	h.NodesSizeOnDisk, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -121,7 +174,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "NodesSizeOnDisk", "read", n, "start position", pos, "value", h.NodesSizeOnDisk)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.AttributesUncompressedSize)
+	h.AttributesUncompressedSize, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -129,7 +182,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "AttributesUncompressedSize", "read", n, "start position", pos, "value", h.AttributesUncompressedSize)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.AttributesSizeOnDisk)
+	h.AttributesSizeOnDisk, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -137,7 +190,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "AttributesSizeOnDisk", "read", n, "start position", pos, "value", h.AttributesSizeOnDisk)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.ValuesUncompressedSize)
+	h.ValuesUncompressedSize, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -145,7 +198,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "ValuesUncompressedSize", "read", n, "start position", pos, "value", h.ValuesUncompressedSize)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.ValuesSizeOnDisk)
+	h.ValuesSizeOnDisk, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -153,7 +206,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "ValuesSizeOnDisk", "read", n, "start position", pos, "value", h.ValuesSizeOnDisk)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.CompressionFlags)
+	h.CompressionFlags, err = lsgo.ReadUint8(r)
 	n = 1
 	if err != nil {
 		return err
@@ -161,7 +214,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "CompressionFlags", "read", n, "start position", pos, "value", h.CompressionFlags)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Unknown2)
+	h.Unknown2, err = lsgo.ReadUint8(r)
 	n = 1
 	if err != nil {
 		return err
@@ -169,7 +222,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Unknown2", "read", n, "start position", pos, "value", h.Unknown2)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Unknown3)
+	h.Unknown3, err = lsgo.ReadUint16(r, binary.LittleEndian)
 	n = 2
 	if err != nil {
 		return err
@@ -177,7 +230,7 @@ func (h *Header) Read(r io.ReadSeeker) error {
 	l.Log("member", "Unknown3", "read", n, "start position", pos, "value", h.Unknown3)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &h.Extended)
+	h.Extended, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -190,10 +243,71 @@ func (h *Header) Read(r io.ReadSeeker) error {
 		h.AttributesSizeOnDisk = h.AttributesUncompressedSize
 		h.StringsSizeOnDisk = h.StringsUncompressedSize
 		h.ValuesSizeOnDisk = h.ValuesUncompressedSize
+		h.KeysSizeOnDisk = h.KeysUncompressedSize
+	}
+	return nil
+}
+
+// Write writes h to w, fields that are not present in h.Version are skipped
+func (h *Header) Write(w io.Writer) error {
+	fields := []interface{}{h.Signature, h.Version}
+	if h.Version >= lsgo.VerBG3ExtendedHeader {
+		fields = append(fields, h.EngineVersion)
+	} else {
+		fields = append(fields, uint32(h.EngineVersion))
+	}
+	fields = append(fields, h.StringsUncompressedSize, h.StringsSizeOnDisk)
+	if h.Version >= lsgo.VerBG3AdditionalBlob {
+		fields = append(fields, h.KeysUncompressedSize, h.KeysSizeOnDisk)
+	}
+	fields = append(fields,
+		h.NodesUncompressedSize, h.NodesSizeOnDisk,
+		h.AttributesUncompressedSize, h.AttributesSizeOnDisk,
+		h.ValuesUncompressedSize, h.ValuesSizeOnDisk,
+		h.CompressionFlags, h.Unknown2, h.Unknown3, h.Extended,
+	)
+	for _, v := range fields {
+		err := binary.Write(w, binary.LittleEndian, v)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// SetMetadata encodes m as the engine version of h, h.Version must already be set
+func (h *Header) SetMetadata(m lsgo.LSMetadata) {
+	if h.Version >= lsgo.VerBG3ExtendedHeader {
+		h.EngineVersion = uint64(m.Major&0x7f)<<55 |
+			uint64(m.Minor&0xff)<<47 |
+			uint64(m.Revision&0xffff)<<31 |
+			uint64(m.Build&0x7fffffff)
+		return
+	}
+	h.EngineVersion = uint64(m.Major&0xf)<<28 |
+		uint64(m.Minor&0xf)<<24 |
+		uint64(m.Revision&0xff)<<16 |
+		uint64(m.Build&0xffff)
+}
+
+// Metadata decodes the engine version
+func (h Header) Metadata() lsgo.LSMetadata {
+	if h.Version >= lsgo.VerBG3ExtendedHeader {
+		return lsgo.LSMetadata{
+			Major:    uint32((h.EngineVersion >> 55) & 0x7f),
+			Minor:    uint32((h.EngineVersion >> 47) & 0xff),
+			Revision: uint32((h.EngineVersion >> 31) & 0xffff),
+			Build:    uint32(h.EngineVersion & 0x7fffffff),
+		}
+	}
+	return lsgo.LSMetadata{
+		Major:    uint32((h.EngineVersion & 0xf0000000) >> 28),
+		Minor:    uint32((h.EngineVersion & 0xf000000) >> 24),
+		Revision: uint32((h.EngineVersion & 0xff0000) >> 16),
+		Build:    uint32(h.EngineVersion & 0xffff),
+	}
+}
+
 func (h Header) IsCompressed() bool {
 	return lsgo.CompressionFlagsToMethod(h.CompressionFlags) != lsgo.CMNone && lsgo.CompressionFlagsToMethod(h.CompressionFlags) != lsgo.CMInvalid
 }
@@ -215,22 +329,40 @@ type NodeEntry struct {
 }
 
 func (ne *NodeEntry) Read(r io.ReadSeeker) error {
+	return ne.read(r, ne.logger(lsgo.Logger))
+}
+
+// logger adds the context of ne to logger, it is done once per section
+func (ne *NodeEntry) logger(logger log.Logger) log.Logger {
+	if ne.Long {
+		return lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "long node")
+	}
+	return lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "short node")
+}
+
+func (ne *NodeEntry) read(r io.ReadSeeker, l log.Logger) error {
+	if sr, ok := r.(*lsgo.SliceReader); ok && lsgo.Discards(l) {
+		b, err := sr.Next(entrySize(ne.Long))
+		if err != nil {
+			return err
+		}
+		ne.decode(b)
+		return nil
+	}
 	if ne.Long {
-		return ne.readLong(r)
+		return ne.readLong(r, l)
 	}
-	return ne.readShort(r)
+	return ne.readShort(r, l)
 }
 
-func (ne *NodeEntry) readShort(r io.ReadSeeker) error {
+func (ne *NodeEntry) readShort(r io.ReadSeeker, l log.Logger) error {
 	var (
-		l   log.Logger
 		pos int64
 		err error
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "short node")
 	pos, _ = r.Seek(0, io.SeekCurrent)
-	err = binary.Read(r, binary.LittleEndian, &ne.NameHashTableIndex)
+	ne.NameHashTableIndex, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -238,34 +370,32 @@ func (ne *NodeEntry) readShort(r io.ReadSeeker) error {
 	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", strconv.Itoa(ne.NameIndex())+" "+strconv.Itoa(ne.NameOffset()))
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ne.FirstAttributeIndex)
+	ne.FirstAttributeIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
 	}
-	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", ne.FirstAttributeIndex)
+	l.Log("member", "FirstAttributeIndex", "read", n, "start position", pos, "value", ne.FirstAttributeIndex)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ne.ParentIndex)
+	ne.ParentIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
 	}
-	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", ne.ParentIndex)
+	l.Log("member", "ParentIndex", "read", n, "start position", pos, "value", ne.ParentIndex)
 	pos += int64(n)
 	return nil
 }
 
-func (ne *NodeEntry) readLong(r io.ReadSeeker) error {
+func (ne *NodeEntry) readLong(r io.ReadSeeker, l log.Logger) error {
 	var (
-		l   log.Logger
 		pos int64
 		err error
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "long node")
 	pos, _ = r.Seek(0, io.SeekCurrent)
-	err = binary.Read(r, binary.LittleEndian, &ne.NameHashTableIndex)
+	ne.NameHashTableIndex, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -273,7 +403,7 @@ func (ne *NodeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", strconv.Itoa(ne.NameIndex())+" "+strconv.Itoa(ne.NameOffset()))
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ne.ParentIndex)
+	ne.ParentIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
@@ -281,7 +411,7 @@ func (ne *NodeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "ParentIndex", "read", n, "start position", pos, "value", ne.ParentIndex)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ne.NextSiblingIndex)
+	ne.NextSiblingIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
@@ -289,7 +419,7 @@ func (ne *NodeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "NextSiblingIndex", "read", n, "start position", pos, "value", ne.NextSiblingIndex)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ne.FirstAttributeIndex)
+	ne.FirstAttributeIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
@@ -300,6 +430,20 @@ func (ne *NodeEntry) readLong(r io.ReadSeeker) error {
 	return nil
 }
 
+// decode decodes ne from the entrySize bytes of b
+func (ne *NodeEntry) decode(b []byte) {
+	le := binary.LittleEndian
+	ne.NameHashTableIndex = le.Uint32(b)
+	if ne.Long {
+		ne.ParentIndex = int32(le.Uint32(b[4:]))
+		ne.NextSiblingIndex = int32(le.Uint32(b[8:]))
+		ne.FirstAttributeIndex = int32(le.Uint32(b[12:]))
+		return
+	}
+	ne.FirstAttributeIndex = int32(le.Uint32(b[4:]))
+	ne.ParentIndex = int32(le.Uint32(b[8:]))
+}
+
 func (ne NodeEntry) NameIndex() int {
 	return int(ne.NameHashTableIndex >> 16)
 }
@@ -345,23 +489,41 @@ type AttributeEntry struct {
 }
 
 func (ae *AttributeEntry) Read(r io.ReadSeeker) error {
+	return ae.read(r, ae.logger(lsgo.Logger))
+}
+
+// logger adds the context of ae to logger, it is done once per section
+func (ae *AttributeEntry) logger(logger log.Logger) log.Logger {
+	if ae.Long {
+		return lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "long attribute")
+	}
+	return lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "short attribute")
+}
+
+func (ae *AttributeEntry) read(r io.ReadSeeker, l log.Logger) error {
+	if sr, ok := r.(*lsgo.SliceReader); ok && lsgo.Discards(l) {
+		b, err := sr.Next(entrySize(ae.Long))
+		if err != nil {
+			return err
+		}
+		ae.decode(b)
+		return nil
+	}
 	if ae.Long {
-		return ae.readLong(r)
+		return ae.readLong(r, l)
 	}
-	return ae.readShort(r)
+	return ae.readShort(r, l)
 }
 
-func (ae *AttributeEntry) readShort(r io.ReadSeeker) error {
+func (ae *AttributeEntry) readShort(r io.ReadSeeker, l log.Logger) error {
 	var (
-		l   log.Logger
 		pos int64
 		err error
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "short attribute")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.NameHashTableIndex)
+	ae.NameHashTableIndex, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -369,7 +531,7 @@ func (ae *AttributeEntry) readShort(r io.ReadSeeker) error {
 	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", strconv.Itoa(ae.NameIndex())+" "+strconv.Itoa(ae.NameOffset()))
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.TypeAndLength)
+	ae.TypeAndLength, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -377,7 +539,7 @@ func (ae *AttributeEntry) readShort(r io.ReadSeeker) error {
 	l.Log("member", "TypeAndLength", "read", n, "start position", pos, "value", ae.TypeAndLength)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.NodeIndex)
+	ae.NodeIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
@@ -388,17 +550,15 @@ func (ae *AttributeEntry) readShort(r io.ReadSeeker) error {
 	return nil
 }
 
-func (ae *AttributeEntry) readLong(r io.ReadSeeker) error {
+func (ae *AttributeEntry) readLong(r io.ReadSeeker, l log.Logger) error {
 	var (
-		l   log.Logger
 		pos int64
 		err error
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "long attribute")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.NameHashTableIndex)
+	ae.NameHashTableIndex, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -406,7 +566,7 @@ func (ae *AttributeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "NameHashTableIndex", "read", n, "start position", pos, "value", strconv.Itoa(ae.NameIndex())+" "+strconv.Itoa(ae.NameOffset()))
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.TypeAndLength)
+	ae.TypeAndLength, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -414,7 +574,7 @@ func (ae *AttributeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "TypeAndLength", "read", n, "start position", pos, "value", ae.TypeAndLength)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.NextAttributeIndex)
+	ae.NextAttributeIndex, err = readInt32(r)
 	n = 4
 	if err != nil {
 		return err
@@ -422,7 +582,7 @@ func (ae *AttributeEntry) readLong(r io.ReadSeeker) error {
 	l.Log("member", "NextAttributeIndex", "read", n, "start position", pos, "value", ae.NextAttributeIndex)
 	pos += int64(n)
 
-	err = binary.Read(r, binary.LittleEndian, &ae.Offset)
+	ae.Offset, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return err
@@ -434,6 +594,19 @@ func (ae *AttributeEntry) readLong(r io.ReadSeeker) error {
 }
 
 // Index into name hash table
+// decode decodes ae from the entrySize bytes of b
+func (ae *AttributeEntry) decode(b []byte) {
+	le := binary.LittleEndian
+	ae.NameHashTableIndex = le.Uint32(b)
+	ae.TypeAndLength = le.Uint32(b[4:])
+	if ae.Long {
+		ae.NextAttributeIndex = int32(le.Uint32(b[8:]))
+		ae.Offset = le.Uint32(b[12:])
+		return
+	}
+	ae.NodeIndex = int32(le.Uint32(b[8:]))
+}
+
 func (ae AttributeEntry) NameIndex() int {
 	return int(ae.NameHashTableIndex >> 16)
 }
@@ -475,8 +648,90 @@ type AttributeInfo struct {
 	NextAttributeIndex int
 }
 
+// Node key in the LSF file
+type KeyEntry struct {
+	// Index of the node this key belongs to
+	NodeIndex uint32
+
+	// (16-bit MSB: index into name hash table, 16-bit LSB: offset in hash chain)
+	KeyName uint32
+}
+
+func (ke *KeyEntry) Read(r io.ReadSeeker) error {
+	return ke.read(r, lsgo.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "key"))
+}
+
+func (ke *KeyEntry) read(r io.ReadSeeker, l log.Logger) error {
+	var (
+		pos int64
+		err error
+		n   int
+	)
+	pos, _ = r.Seek(0, io.SeekCurrent)
+
+	ke.NodeIndex, err = lsgo.ReadUint32(r, binary.LittleEndian)
+	n = 4
+	if err != nil {
+		return err
+	}
+	l.Log("member", "NodeIndex", "read", n, "start position", pos, "value", ke.NodeIndex)
+	pos += int64(n)
+
+	ke.KeyName, err = lsgo.ReadUint32(r, binary.LittleEndian)
+	n = 4
+	if err != nil {
+		return err
+	}
+	l.Log("member", "KeyName", "read", n, "start position", pos, "value", strconv.Itoa(ke.NameIndex())+" "+strconv.Itoa(ke.NameOffset()))
+
+	return nil
+}
+
+// Index into name hash table
+func (ke KeyEntry) NameIndex() int {
+	return int(ke.KeyName >> 16)
+}
+
+// Offset in hash chain
+func (ke KeyEntry) NameOffset() int {
+	return int(ke.KeyName & 0xffff)
+}
+
+func readKeys(r io.ReadSeeker, logger log.Logger) ([]KeyEntry, error) {
+	var (
+		keys []KeyEntry
+		err  error
+		l    = lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "key")
+	)
+	for {
+		var key KeyEntry
+		err = key.read(r, l)
+		if err != nil {
+			break
+		}
+		keys = append(keys, key)
+	}
+	if err == io.EOF {
+		return keys, nil
+	}
+	return keys, err
+}
+
 // extract to lsf package
+// ReadNames reads the names section of an LSF file. The names share a single
+// copy of the section so each name is allocated once however many nodes and
+// attributes use it
 func ReadNames(r io.ReadSeeker) ([][]string, error) {
+	b, err := lsgo.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return readNames(lsgo.NewSliceReader(b), nil, lsgo.Logger)
+}
+
+// readNames reads the names section from r, the names are interned with in
+// if it is not nil
+func readNames(r *lsgo.SliceReader, in *lsgo.Interner, logger log.Logger) ([][]string, error) {
 	var (
 		numHashEntries uint32
 		err            error
@@ -486,28 +741,42 @@ func ReadNames(r io.ReadSeeker) ([][]string, error) {
 		pos int64
 		n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "names")
+	l = lsgo.With(logger, "component", "LS converter", "file type", "lsf", "part", "names")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
-	err = binary.Read(r, binary.LittleEndian, &numHashEntries)
+	// Without an interner the names are slices of this string, r may be a
+	// memory mapped file
+	var section string
+	if in == nil {
+		section = string(r.Bytes())
+	}
+
+	numHashEntries, err = lsgo.ReadUint32(r, binary.LittleEndian)
 	n = 4
 	if err != nil {
 		return nil, err
 	}
 	l.Log("member", "numHashEntries", "read", n, "start position", pos, "value", numHashEntries)
 	pos += int64(n)
+	// Every hash entry is at least 2 bytes, don't allocate more than the section can hold
+	if int64(numHashEntries)*2 > int64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
 
 	names = make([][]string, int(numHashEntries))
 	for i := range names {
 		var numStrings uint16
 
-		err = binary.Read(r, binary.LittleEndian, &numStrings)
-		n = 4
+		numStrings, err = lsgo.ReadUint16(r, binary.LittleEndian)
+		n = 2
 		if err != nil {
 			return nil, err
 		}
 		l.Log("member", "numStrings", "read", n, "start position", pos, "value", numStrings)
 		pos += int64(n)
+		if int(numStrings)*2 > r.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
 
 		hash := make([]string, int(numStrings))
 		for x := range hash {
@@ -515,7 +784,7 @@ func ReadNames(r io.ReadSeeker) ([][]string, error) {
 				nameLen uint16
 				name    []byte
 			)
-			err = binary.Read(r, binary.LittleEndian, &nameLen)
+			nameLen, err = lsgo.ReadUint16(r, binary.LittleEndian)
 			n = 2
 			if err != nil {
 				return nil, err
@@ -523,26 +792,37 @@ func ReadNames(r io.ReadSeeker) ([][]string, error) {
 			l.Log("member", "nameLen", "read", n, "start position", pos, "value", nameLen)
 			pos += int64(n)
 
-			name = make([]byte, nameLen)
-
-			n, err = r.Read(name)
+			name, err = lsgo.ReadBytes(r, int(nameLen))
+			n = len(name)
 			if err != nil {
 				return nil, err
 			}
 			l.Log("member", "name", "read", n, "start position", pos, "value", name)
+			if in != nil {
+				hash[x] = in.Bytes(name)
+			} else {
+				hash[x] = section[pos : pos+int64(n)]
+			}
 			pos += int64(n)
-
-			hash[x] = string(name)
 		}
 		names[i] = hash
 	}
 	return names, nil
 }
 
-func readNodeInfo(r io.ReadSeeker, longNodes bool) ([]NodeInfo, error) {
+// entrySize returns the size of a node or attribute entry, they are the same
+func entrySize(long bool) int {
+	if long {
+		return 16
+	}
+	return 12
+}
+
+func readNodeInfo(r *lsgo.SliceReader, longNodes bool, logger log.Logger) ([]NodeInfo, error) {
 	var (
-		nodes []NodeInfo
+		nodes = make([]NodeInfo, 0, r.Len()/entrySize(longNodes)+1)
 		err   error
+		l     = (&NodeEntry{Long: longNodes}).logger(logger)
 	)
 	index := 0
 
@@ -550,7 +830,7 @@ func readNodeInfo(r io.ReadSeeker, longNodes bool) ([]NodeInfo, error) {
 		var node NodeInfo
 
 		item := &NodeEntry{Long: longNodes}
-		err = item.Read(r)
+		err = item.read(r, l)
 
 		node.FirstAttributeIndex = int(item.FirstAttributeIndex)
 		node.NameIndex = item.NameIndex()
@@ -565,7 +845,7 @@ func readNodeInfo(r io.ReadSeeker, longNodes bool) ([]NodeInfo, error) {
 
 // Reads the attribute headers for the LSOF resource
 // <param name="s">Stream to read the attribute headers from</param>
-func readAttributeInfo(r io.ReadSeeker, long bool) []AttributeInfo {
+func readAttributeInfo(r *lsgo.SliceReader, long bool, logger log.Logger) []AttributeInfo {
 	// var rawAttributes = new List<AttributeEntryV2>();
 
 	var (
@@ -573,12 +853,13 @@ func readAttributeInfo(r io.ReadSeeker, long bool) []AttributeInfo {
 		dataOffset        uint = 0
 		index                  = 0
 		nextAttrIndex     int  = -1
-		attributes        []AttributeInfo
+		attributes             = make([]AttributeInfo, 0, r.Len()/entrySize(long))
 		err               error
+		l                 = (&AttributeEntry{Long: long}).logger(logger)
 	)
 	for err == nil {
 		attribute := &AttributeEntry{Long: long}
-		err = attribute.Read(r)
+		err = attribute.read(r, l)
 		if err != nil {
 			break
 		}
@@ -635,7 +916,136 @@ func readAttributeInfo(r io.ReadSeeker, long bool) []AttributeInfo {
 	// }
 }
 
-func Read(r io.ReadSeeker) (lsgo.Resource, error) {
+// sections holds the decoded tables of an LSF file, attribute values are
+// decoded on demand from values
+type sections struct {
+	hdr Header
+
+	// Static string hash map
+	names [][]string
+
+	// Preprocessed list of nodes (structures)
+	nodeInfo []NodeInfo
+
+	// Preprocessed list of node attributes
+	attributeInfo []AttributeInfo
+
+	// Node keys indexed by node index
+	keys map[int]string
+
+	// Uncompressed value section
+	values io.ReadSeeker
+
+	guidMode lsgo.GUIDMode
+	opts     lsgo.Options
+
+	// Child lists, built on the first call to children
+	firstChild  []int
+	nextSibling []int
+}
+
+// checkLimits returns an error if a section of h is larger than limits allows
+func (h Header) checkLimits(limits lsgo.Limits) error {
+	for _, size := range []uint32{
+		h.StringsUncompressedSize, h.StringsSizeOnDisk,
+		h.KeysUncompressedSize, h.KeysSizeOnDisk,
+		h.NodesUncompressedSize, h.NodesSizeOnDisk,
+		h.AttributesUncompressedSize, h.AttributesSizeOnDisk,
+		h.ValuesUncompressedSize, h.ValuesSizeOnDisk,
+	} {
+		err := limits.CheckSectionSize(int64(size))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionEnd checks that all of a section was read
+func sectionEnd(l log.Logger, opts lsgo.Options, section string, pos, want int64) error {
+	if pos == want {
+		return nil
+	}
+	if opts.Strict {
+		return fmt.Errorf("%w: %s ends at %d, expected %d", lsgo.ErrSectionSize, section, pos, want)
+	}
+	l.Log("member", section, "msg", "section not fully read", "current", pos, "wanted", want)
+	return nil
+}
+
+// A dataSection is one of the sections following the header of an LSF file
+type dataSection struct {
+	name             string
+	sizeOnDisk       uint32
+	uncompressedSize uint32
+	chunked          bool
+
+	// Contents of the section, decompressed by Header.decompress
+	data []byte
+}
+
+// dataSections returns the sections of the file described by h in file order
+func (h Header) dataSections() []dataSection {
+	chunked := h.Version >= lsgo.VerChunkedCompress
+	return []dataSection{
+		{name: "names", sizeOnDisk: h.StringsSizeOnDisk, uncompressedSize: h.StringsUncompressedSize},
+		{name: "nodes", sizeOnDisk: h.NodesSizeOnDisk, uncompressedSize: h.NodesUncompressedSize, chunked: chunked},
+		{name: "attributes", sizeOnDisk: h.AttributesSizeOnDisk, uncompressedSize: h.AttributesUncompressedSize, chunked: chunked},
+		{name: "values", sizeOnDisk: h.ValuesSizeOnDisk, uncompressedSize: h.ValuesUncompressedSize, chunked: chunked},
+		{name: "keys", sizeOnDisk: h.KeysSizeOnDisk, uncompressedSize: h.KeysUncompressedSize, chunked: chunked},
+	}
+}
+
+// diskSize returns the number of bytes the section uses in the file.
+// A section without a size on disk is stored uncompressed, the same as LSLib
+func (ds dataSection) diskSize() uint32 {
+	if ds.sizeOnDisk == 0 {
+		return ds.uncompressedSize
+	}
+	return ds.sizeOnDisk
+}
+
+// compressed reports whether the section is compressed in a file with the header h
+func (ds dataSection) compressed(h Header) bool {
+	return h.IsCompressed() && ds.sizeOnDisk != 0
+}
+
+// read reads the section as it is stored in the file from r. If r is a
+// *lsgo.SliceReader the data is not copied
+func (ds *dataSection) read(r io.Reader) error {
+	var err error
+	ds.data, err = lsgo.ReadBytes(r, int(ds.diskSize()))
+	return err
+}
+
+// decompress decompresses secs concurrently, they are independent once the
+// header is known. Each section is decompressed into a buffer of exactly its
+// uncompressed size
+func (h Header) decompress(secs []dataSection) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(secs))
+	)
+	for i := range secs {
+		if !secs[i].compressed(h) {
+			continue
+		}
+		wg.Add(1)
+		go func(ds *dataSection, err *error) {
+			defer wg.Done()
+			ds.data, *err = lsgo.DecompressBytes(ds.data, int(ds.uncompressedSize), h.CompressionFlags, ds.chunked)
+		}(&secs[i], &errs[i])
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("LSF %s: %w", secs[i].name, err)
+		}
+	}
+	return nil
+}
+
+func readSections(r io.ReadSeeker, opts lsgo.Options) (*sections, error) {
 	var (
 		err error
 
@@ -648,171 +1058,463 @@ func Read(r io.ReadSeeker) (lsgo.Resource, error) {
 		// Preprocessed list of node attributes
 		attributeInfo []AttributeInfo
 
-		// Node instances
-		nodeInstances []*lsgo.Node
+		// Node keys
+		keys []KeyEntry
 	)
 	var (
-		l         log.Logger
-		pos, npos int64
+		l   log.Logger
+		pos int64
 		// n   int
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "file")
+	l = opts.With("component", "LS converter", "file type", "lsf", "part", "file")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 	l.Log("member", "header", "start position", pos)
 
 	hdr := &Header{}
-	err = hdr.Read(r)
+	err = hdr.read(r, opts.With())
 	if err != nil || (string(hdr.Signature[:]) != Signature) {
-		return lsgo.Resource{}, lsgo.HeaderError{Expected: Signature, Got: hdr.Signature[:]}
+		return nil, lsgo.HeaderError{Expected: Signature, Got: hdr.Signature[:]}
 	}
 
 	if hdr.Version < lsgo.VerInitial || hdr.Version > lsgo.MaxVersion {
-		return lsgo.Resource{}, fmt.Errorf("LSF version %v is not supported", hdr.Version)
+		return nil, fmt.Errorf("LSF version %v is not supported", hdr.Version)
+	}
+	err = hdr.checkLimits(opts.Limits)
+	if err != nil {
+		return nil, err
 	}
 
-	isCompressed := lsgo.CompressionFlagsToMethod(hdr.CompressionFlags) != lsgo.CMNone && lsgo.CompressionFlagsToMethod(hdr.CompressionFlags) != lsgo.CMInvalid
-
-	pos, _ = r.Seek(0, io.SeekCurrent)
-	l.Log("member", "LSF names", "start position", pos)
-	if hdr.StringsSizeOnDisk > 0 || hdr.StringsUncompressedSize > 0 {
-		uncompressed := lsgo.LimitReadSeeker(r, int64(hdr.StringsSizeOnDisk))
-
-		if isCompressed {
-			uncompressed = lsgo.Decompress(uncompressed, int(hdr.StringsUncompressedSize), hdr.CompressionFlags, false)
+	// The keys are stored after the values
+	secs := hdr.dataSections()
+	for i := range secs {
+		pos, _ = r.Seek(0, io.SeekCurrent)
+		l.Log("member", "LSF "+secs[i].name, "start position", pos)
+		err = secs[i].read(r)
+		if err != nil {
+			return nil, err
 		}
+	}
+	err = hdr.decompress(secs)
+	if err != nil {
+		return nil, err
+	}
 
-		// using (var nodesFile = new FileStream("names.bin", FileMode.Create, FileAccess.Write))
-		// {
-		//     nodesFile.Write(uncompressed, 0, uncompressed.Length);
-		// }
-
-		names, err = ReadNames(uncompressed)
+	if data := secs[0].data; len(data) > 0 {
+		sr := lsgo.NewSliceReader(data)
+		names, err = readNames(sr, opts.Interner, opts.With())
 		if err != nil && err != io.EOF {
-			return lsgo.Resource{}, err
+			return nil, err
 		}
-	}
-
-	npos, _ = r.Seek(0, io.SeekCurrent)
-	l.Log("member", "LSF nodes", "start position", npos)
-	if npos != pos+int64(hdr.StringsSizeOnDisk) {
-		l.Log("member", "LSF nodes", "msg", "seeking to correct offset", "current", npos, "wanted", pos+int64(hdr.StringsSizeOnDisk))
-		pos, _ = r.Seek((pos+int64(hdr.StringsSizeOnDisk))-npos, io.SeekCurrent)
-	} else {
-		pos = npos
-	}
-	if hdr.NodesSizeOnDisk > 0 || hdr.NodesUncompressedSize > 0 {
-		uncompressed := lsgo.LimitReadSeeker(r, int64(hdr.NodesSizeOnDisk))
-		if isCompressed {
-			uncompressed = lsgo.Decompress(uncompressed, int(hdr.NodesUncompressedSize), hdr.CompressionFlags, hdr.Version >= lsgo.VerChunkedCompress)
+		err = sectionEnd(l, opts, "LSF names", int64(len(data)-sr.Len()), int64(len(data)))
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// using (var nodesFile = new FileStream("nodes.bin", FileMode.Create, FileAccess.Write))
-		// {
-		//     nodesFile.Write(uncompressed, 0, uncompressed.Length);
-		// }
-
+	if data := secs[1].data; len(data) > 0 {
 		longNodes := hdr.Version >= lsgo.VerExtendedNodes && hdr.Extended == 1
-		nodeInfo, err = readNodeInfo(uncompressed, longNodes)
+		nodeInfo, err = readNodeInfo(lsgo.NewSliceReader(data), longNodes, opts.With())
 		if err != nil && err != io.EOF {
-			return lsgo.Resource{}, err
+			return nil, err
+		}
+		err = opts.Limits.CheckNodes(len(nodeInfo))
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	npos, _ = r.Seek(0, io.SeekCurrent)
-	l.Log("member", "LSF attributes", "start position", npos)
-	if npos != pos+int64(hdr.NodesSizeOnDisk) {
-		l.Log("msg", "seeking to correct offset", "current", npos, "wanted", pos+int64(hdr.NodesSizeOnDisk))
-		pos, _ = r.Seek((pos+int64(hdr.NodesSizeOnDisk))-npos, io.SeekCurrent)
-	} else {
-		pos = npos
-	}
-	if hdr.AttributesSizeOnDisk > 0 || hdr.AttributesUncompressedSize > 0 {
-		var uncompressed io.ReadSeeker = lsgo.LimitReadSeeker(r, int64(hdr.AttributesSizeOnDisk))
-		if isCompressed {
-			uncompressed = lsgo.Decompress(uncompressed, int(hdr.AttributesUncompressedSize), hdr.CompressionFlags, hdr.Version >= lsgo.VerChunkedCompress)
+	if data := secs[2].data; len(data) > 0 {
+		longAttributes := hdr.Version >= lsgo.VerExtendedNodes && hdr.Extended == 1
+		attributeInfo = readAttributeInfo(lsgo.NewSliceReader(data), longAttributes, opts.With())
+		err = opts.Limits.CheckAttributes(len(attributeInfo))
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// using (var attributesFile = new FileStream("attributes.bin", FileMode.Create, FileAccess.Write))
-		// {
-		//     attributesFile.Write(uncompressed, 0, uncompressed.Length);
-		// }
+	values := secs[3].data
 
-		longAttributes := hdr.Version >= lsgo.VerExtendedNodes && hdr.Extended == 1
-		attributeInfo = readAttributeInfo(uncompressed, longAttributes)
+	if data := secs[4].data; len(data) > 0 {
+		keys, err = readKeys(lsgo.NewSliceReader(data), opts.With())
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	npos, _ = r.Seek(0, io.SeekCurrent)
-	l.Log("member", "LSF values", "start position", npos)
-	if npos != pos+int64(hdr.AttributesSizeOnDisk) {
-		l.Log("msg", "seeking to correct offset", "current", npos, "wanted", pos+int64(hdr.AttributesSizeOnDisk))
-		pos, _ = r.Seek((pos+int64(hdr.AttributesSizeOnDisk))-npos, io.SeekCurrent)
-	} else {
-		pos = npos
+	s := &sections{
+		hdr:           *hdr,
+		names:         names,
+		nodeInfo:      nodeInfo,
+		attributeInfo: attributeInfo,
+		keys:          make(map[int]string, len(keys)),
+		values:        lsgo.NewSliceReader(values),
+		guidMode:      opts.GUIDModeFor(hdr.Metadata()),
+		opts:          opts,
 	}
-	var uncompressed io.ReadSeeker = lsgo.LimitReadSeeker(r, int64(hdr.ValuesSizeOnDisk))
-	if hdr.ValuesSizeOnDisk > 0 || hdr.ValuesUncompressedSize > 0 {
-		if isCompressed {
-			uncompressed = lsgo.Decompress(r, int(hdr.ValuesUncompressedSize), hdr.CompressionFlags, hdr.Version >= lsgo.VerChunkedCompress)
+	for _, key := range keys {
+		if int(key.NodeIndex) >= len(nodeInfo) {
+			return nil, lsgo.ErrKeyDoesNotMatch
 		}
+		if key.NameIndex() >= len(names) || key.NameOffset() >= len(names[key.NameIndex()]) {
+			return nil, lsgo.ErrInvalidNameKey
+		}
+		s.keys[int(key.NodeIndex)] = names[key.NameIndex()][key.NameOffset()]
+	}
+	return s, nil
+}
+
+// Read decodes the LSF file in r
+func Read(r io.ReadSeeker) (lsgo.Resource, error) {
+	return ReadWithOptions(r, lsgo.Options{})
+}
+
+// ReadWithOptions decodes the LSF file in r using opts
+func ReadWithOptions(r io.ReadSeeker, opts lsgo.Options) (lsgo.Resource, error) {
+	s, err := readSections(r, opts)
+	if err != nil {
+		return lsgo.Resource{}, err
 	}
 
 	res := lsgo.Resource{}
-	valueStart, _ := uncompressed.Seek(0, io.SeekCurrent)
-	nodeInstances, err = ReadRegions(uncompressed, valueStart, names, nodeInfo, attributeInfo, hdr.Version, hdr.EngineVersion)
+	res.Metadata = s.hdr.Metadata()
+	res.GUIDMode = s.guidMode
+
+	nodeInstances, err := ReadRegions(s.values, 0, s.names, s.nodeInfo, s.attributeInfo, s.hdr.Version, uint32(s.hdr.EngineVersion), s.guidMode, s.opts)
 	if err != nil {
 		return res, err
 	}
+	for i, key := range s.keys {
+		nodeInstances[i].Key = key
+	}
 	for _, v := range nodeInstances {
 		if v.Parent == nil {
 			res.Regions = append(res.Regions, v)
 		}
 	}
 
-	res.Metadata.Major = (hdr.EngineVersion & 0xf0000000) >> 28
-	res.Metadata.Minor = (hdr.EngineVersion & 0xf000000) >> 24
-	res.Metadata.Revision = (hdr.EngineVersion & 0xff0000) >> 16
-	res.Metadata.Build = (hdr.EngineVersion & 0xffff)
-
 	return res, nil
 }
 
-func ReadRegions(r io.ReadSeeker, valueStart int64, names [][]string, nodeInfo []NodeInfo, attributeInfo []AttributeInfo, version lsgo.FileVersion, engineVersion uint32) ([]*lsgo.Node, error) {
-	NodeInstances := make([]*lsgo.Node, 0, len(nodeInfo))
-	for _, nodeInfo := range nodeInfo {
-		if nodeInfo.ParentIndex == -1 {
-			region, err := ReadNode(r, valueStart, nodeInfo, names, attributeInfo, version, engineVersion)
-
-			region.RegionName = region.Name
-			NodeInstances = append(NodeInstances, &region)
-
-			if err != nil {
-				return NodeInstances, err
-			}
-		} else {
-			node, err := ReadNode(r, valueStart, nodeInfo, names, attributeInfo, version, engineVersion)
-
-			node.Parent = NodeInstances[nodeInfo.ParentIndex]
-			NodeInstances = append(NodeInstances, &node)
-			NodeInstances[nodeInfo.ParentIndex].AppendChild(&node)
-
-			if err != nil {
-				return NodeInstances, err
-			}
+// children builds the child lists of every node
+func (s *sections) children() {
+	if s.firstChild != nil {
+		return
+	}
+	s.firstChild = make([]int, len(s.nodeInfo))
+	s.nextSibling = make([]int, len(s.nodeInfo))
+	for i := range s.firstChild {
+		s.firstChild[i] = -1
+		s.nextSibling[i] = -1
+	}
+	// Iterate backwards so that children end up in file order
+	for i := len(s.nodeInfo) - 1; i >= 0; i-- {
+		parent := s.nodeInfo[i].ParentIndex
+		if parent < 0 || parent >= len(s.nodeInfo) {
+			continue
 		}
+		s.nextSibling[i] = s.firstChild[parent]
+		s.firstChild[parent] = i
 	}
-	return NodeInstances, nil
 }
 
-func ReadNode(r io.ReadSeeker, valueStart int64, ni NodeInfo, names [][]string, attributeInfo []AttributeInfo, version lsgo.FileVersion, engineVersion uint32) (lsgo.Node, error) {
-	var (
-		node  = lsgo.Node{}
-		index = ni.FirstAttributeIndex
-		err   error
+// readAttribute decodes the value of the attribute at index
+func (s *sections) readAttribute(index int) (lsgo.NodeAttribute, error) {
+	attribute := s.attributeInfo[index]
+	_, err := s.values.Seek(int64(attribute.DataOffset), io.SeekStart)
+	if err != nil {
+		return lsgo.NodeAttribute{}, err
+	}
+	return ReadLSFAttribute(s.values, s.names[attribute.NameIndex][attribute.NameOffset], attribute.TypeID, attribute.Length, s.hdr.Version, uint32(s.hdr.EngineVersion), s.guidMode, s.opts)
+}
 
-		l   log.Logger
-		pos int64
+// readTree decodes the node at index and all of its descendants
+func (s *sections) readTree(index int, parent *lsgo.Node) (*lsgo.Node, error) {
+	s.children()
+
+	ni := s.nodeInfo[index]
+	node := &lsgo.Node{
+		Name:   s.names[ni.NameIndex][ni.NameOffset],
+		Key:    s.keys[index],
+		Parent: parent,
+	}
+	for attr := ni.FirstAttributeIndex; attr != -1; attr = s.attributeInfo[attr].NextAttributeIndex {
+		na, err := s.readAttribute(attr)
+		if err != nil {
+			return node, err
+		}
+		node.Attributes = append(node.Attributes, na)
+	}
+	for child := s.firstChild[index]; child != -1; child = s.nextSibling[child] {
+		c, err := s.readTree(child, node)
+		if err != nil {
+			return node, err
+		}
+		node.AppendChild(c)
+	}
+	return node, nil
+}
+
+// File is an LSF file whose regions are decoded on demand.
+// The name, node and attribute tables are decoded once by Open, the nodes
+// and attribute values of a region are decoded the first time it is requested
+type File struct {
+	s *sections
+
+	// Region names in file order, a name is repeated if more than one
+	// region has it
+	regionNames []string
+	// Node indexes of the regions of each name in file order
+	regions map[string][]int
+
+	mu    sync.Mutex
+	cache map[int]*lsgo.Node
+}
+
+// Open decodes the tables of the LSF file in r, r must not be used
+// by anything else while the returned File is in use
+func Open(r io.ReadSeeker) (*File, error) {
+	return OpenWithOptions(r, lsgo.Options{})
+}
+
+// OpenWithOptions is Open using opts, the regions are decoded using opts too
+func OpenWithOptions(r io.ReadSeeker, opts lsgo.Options) (*File, error) {
+	s, err := readSections(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{
+		s:       s,
+		regions: make(map[string][]int),
+		cache:   make(map[int]*lsgo.Node),
+	}
+	for i, ni := range s.nodeInfo {
+		if ni.ParentIndex != -1 {
+			continue
+		}
+		name := s.names[ni.NameIndex][ni.NameOffset]
+		f.regions[name] = append(f.regions[name], i)
+		f.regionNames = append(f.regionNames, name)
+	}
+	return f, nil
+}
+
+// Header returns the header of the file
+func (f *File) Header() Header {
+	return f.s.hdr
+}
+
+// Metadata returns the engine version of the file
+func (f *File) Metadata() lsgo.LSMetadata {
+	return f.s.hdr.Metadata()
+}
+
+// NameCount returns the number of strings in the name hash table
+func (f *File) NameCount() int {
+	n := 0
+	for _, bucket := range f.s.names {
+		n += len(bucket)
+	}
+	return n
+}
+
+// NodeCount returns the number of nodes in the file, including regions
+func (f *File) NodeCount() int {
+	return len(f.s.nodeInfo)
+}
+
+// AttributeCount returns the number of attributes in the file
+func (f *File) AttributeCount() int {
+	return len(f.s.attributeInfo)
+}
+
+// KeyCount returns the number of nodes with a key
+func (f *File) KeyCount() int {
+	return len(f.s.keys)
+}
+
+// RegionNames returns the names of the regions in file order. A name is
+// repeated if more than one region has it
+func (f *File) RegionNames() []string {
+	return append([]string(nil), f.regionNames...)
+}
+
+// Region decodes the first region called name. Repeated calls return the
+// same node, it must not be modified
+func (f *File) Region(name string) (*lsgo.Node, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	indexes, ok := f.regions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", lsgo.ErrRegionNotFound, name)
+	}
+	return f.region(indexes[0])
+}
+
+// Regions decodes every region called name in file order, most files have
+// one region of each name. Repeated calls return the same nodes, they must
+// not be modified
+func (f *File) Regions(name string) ([]*lsgo.Node, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	indexes, ok := f.regions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", lsgo.ErrRegionNotFound, name)
+	}
+	regions := make([]*lsgo.Node, len(indexes))
+	for i, index := range indexes {
+		region, err := f.region(index)
+		if err != nil {
+			return nil, err
+		}
+		regions[i] = region
+	}
+	return regions, nil
+}
+
+// region decodes the region at node index, f.mu must be held
+func (f *File) region(index int) (*lsgo.Node, error) {
+	if region, ok := f.cache[index]; ok {
+		return region, nil
+	}
+	region, err := f.s.readTree(index, nil)
+	if err != nil {
+		return nil, err
+	}
+	region.RegionName = region.Name
+	f.cache[index] = region
+	return region, nil
+}
+
+// SkipChildren is used as a return value from a Visitor to indicate that the
+// remaining attributes and the children of the current node are to be skipped.
+// It is not returned as an error by any function.
+var SkipChildren = errors.New("skip children")
+
+// Stop is used as a return value from a Visitor to indicate that the walk is
+// to be stopped. It is not returned as an error by any function.
+var Stop = errors.New("stop walking")
+
+// Visitor is called by Walk for every node and attribute in an LSF file
+type Visitor interface {
+	// EnterNode is called before the attributes and children of a node.
+	// Region nodes have a depth of 0
+	EnterNode(name string, depth int) error
+
+	// Attribute is called for every attribute of the current node
+	Attribute(attr lsgo.NodeAttribute) error
+
+	// ExitNode is called after the attributes and children of a node,
+	// including when they were skipped
+	ExitNode()
+}
+
+// Walk decodes the LSF file in r and calls v for every node and attribute
+// in file order, without building the tree of nodes.
+// Returning SkipChildren or Stop from v prunes the walk, any other error
+// stops the walk and is returned by Walk
+func Walk(r io.ReadSeeker, v Visitor) error {
+	return WalkWithOptions(r, v, lsgo.Options{})
+}
+
+// WalkWithOptions is Walk using opts
+func WalkWithOptions(r io.ReadSeeker, v Visitor, opts lsgo.Options) error {
+	s, err := readSections(r, opts)
+	if err != nil {
+		return err
+	}
+	for i, ni := range s.nodeInfo {
+		if ni.ParentIndex != -1 {
+			continue
+		}
+		err = s.walk(i, 0, v)
+		if err == Stop {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sections) walk(index, depth int, v Visitor) error {
+	s.children()
+
+	ni := s.nodeInfo[index]
+	err := v.EnterNode(s.names[ni.NameIndex][ni.NameOffset], depth)
+	if err == SkipChildren {
+		v.ExitNode()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for attr := ni.FirstAttributeIndex; attr != -1; attr = s.attributeInfo[attr].NextAttributeIndex {
+		var na lsgo.NodeAttribute
+		na, err = s.readAttribute(attr)
+		if err != nil {
+			return err
+		}
+		err = v.Attribute(na)
+		if err == SkipChildren {
+			v.ExitNode()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for child := s.firstChild[index]; child != -1; child = s.nextSibling[child] {
+		err = s.walk(child, depth+1, v)
+		if err != nil {
+			return err
+		}
+	}
+	v.ExitNode()
+	return nil
+}
+
+func ReadRegions(r io.ReadSeeker, valueStart int64, names [][]string, nodeInfo []NodeInfo, attributeInfo []AttributeInfo, version lsgo.FileVersion, engineVersion uint32, guidMode lsgo.GUIDMode, opts lsgo.Options) ([]*lsgo.Node, error) {
+	NodeInstances := make([]*lsgo.Node, 0, len(nodeInfo))
+	for _, nodeInfo := range nodeInfo {
+		if nodeInfo.ParentIndex == -1 {
+			region, err := ReadNode(r, valueStart, nodeInfo, names, attributeInfo, version, engineVersion, guidMode, opts)
+
+			region.RegionName = region.Name
+			NodeInstances = append(NodeInstances, &region)
+
+			if err != nil {
+				return NodeInstances, err
+			}
+		} else {
+			node, err := ReadNode(r, valueStart, nodeInfo, names, attributeInfo, version, engineVersion, guidMode, opts)
+
+			node.Parent = NodeInstances[nodeInfo.ParentIndex]
+			NodeInstances = append(NodeInstances, &node)
+			NodeInstances[nodeInfo.ParentIndex].AppendChild(&node)
+
+			if err != nil {
+				return NodeInstances, err
+			}
+		}
+	}
+	return NodeInstances, nil
+}
+
+func ReadNode(r io.ReadSeeker, valueStart int64, ni NodeInfo, names [][]string, attributeInfo []AttributeInfo, version lsgo.FileVersion, engineVersion uint32, guidMode lsgo.GUIDMode, opts lsgo.Options) (lsgo.Node, error) {
+	var (
+		node  = lsgo.Node{}
+		index = ni.FirstAttributeIndex
+		err   error
+
+		l   log.Logger
+		pos int64
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "node")
+	l = opts.With("component", "LS converter", "file type", "lsf", "part", "node")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	node.Name = names[ni.NameIndex][ni.NameOffset]
@@ -827,11 +1529,11 @@ func ReadNode(r io.ReadSeeker, valueStart int64, ni NodeInfo, names [][]string,
 
 		if valueStart+int64(attribute.DataOffset) != pos {
 			pos, err = r.Seek(valueStart+int64(attribute.DataOffset), io.SeekStart)
-			if valueStart+int64(attribute.DataOffset) != pos || err != nil {
-				panic("shit")
+			if err != nil {
+				return node, err
 			}
 		}
-		v, err = ReadLSFAttribute(r, names[attribute.NameIndex][attribute.NameOffset], attribute.TypeID, attribute.Length, version, engineVersion)
+		v, err = ReadLSFAttribute(r, names[attribute.NameIndex][attribute.NameOffset], attribute.TypeID, attribute.Length, version, engineVersion, guidMode, opts)
 		node.Attributes = append(node.Attributes, v)
 		if err != nil {
 			return node, err
@@ -841,7 +1543,29 @@ func ReadNode(r io.ReadSeeker, valueStart int64, ni NodeInfo, names [][]string,
 	return node, nil
 }
 
-func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uint, version lsgo.FileVersion, engineVersion uint32) (lsgo.NodeAttribute, error) {
+// ReadLSFAttribute decodes an attribute value of length bytes from r.
+// If opts is strict the value must use exactly length bytes
+func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uint, version lsgo.FileVersion, engineVersion uint32, guidMode lsgo.GUIDMode, opts lsgo.Options) (lsgo.NodeAttribute, error) {
+	err := opts.Limits.CheckStringLength(int(length))
+	if err != nil {
+		return lsgo.NodeAttribute{Type: dt, Name: name}, err
+	}
+	start, _ := r.Seek(0, io.SeekCurrent)
+	attr, err := readLSFAttribute(r, name, dt, length, version, engineVersion, guidMode, opts.Interner, opts.With("component", "LS converter", "file type", "lsf", "part", "attribute"))
+	if err != nil || !opts.Strict {
+		return attr, err
+	}
+	end, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return attr, err
+	}
+	if end-start != int64(length) {
+		return attr, fmt.Errorf("%w: %s is %d bytes, %d were read", lsgo.ErrAttributeSize, name, length, end-start)
+	}
+	return attr, nil
+}
+
+func readLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uint, version lsgo.FileVersion, engineVersion uint32, guidMode lsgo.GUIDMode, in *lsgo.Interner, l log.Logger) (lsgo.NodeAttribute, error) {
 	// LSF and LSB serialize the buffer types differently, so specialized
 	// code is added to the LSB and LSf serializers, and the common code is
 	// available in BinUtils.ReadAttribute()
@@ -852,16 +1576,18 @@ func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uin
 		}
 		err error
 
-		l   log.Logger
 		pos int64
 	)
-	l = log.With(lsgo.Logger, "component", "LS converter", "file type", "lsf", "part", "attribute")
 	pos, _ = r.Seek(0, io.SeekCurrent)
 
 	switch dt {
 	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString, lsgo.DTWString, lsgo.DTLSWString:
 		var v string
-		v, err = lsgo.ReadCString(r, int(length))
+		if dt == lsgo.DTFixedString {
+			v, err = in.ReadCString(r, int(length))
+		} else {
+			v, err = lsgo.ReadCString(r, int(length))
+		}
 		attr.Value = v
 
 		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
@@ -871,7 +1597,7 @@ func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uin
 
 	case lsgo.DTTranslatedString:
 		var v lsgo.TranslatedString
-		v, err = lsgo.ReadTranslatedString(r, version, engineVersion)
+		v, err = lsgo.ReadTranslatedString(r, binary.LittleEndian, version, engineVersion)
 		attr.Value = v
 
 		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
@@ -881,7 +1607,7 @@ func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uin
 
 	case lsgo.DTTranslatedFSString:
 		var v lsgo.TranslatedFSString
-		v, err = lsgo.ReadTranslatedFSString(r, version)
+		v, err = lsgo.ReadTranslatedFSString(r, binary.LittleEndian, version)
 		attr.Value = v
 
 		l.Log("member", name, "read", length, "start position", pos, "value", attr.Value)
@@ -901,10 +1627,595 @@ func ReadLSFAttribute(r io.ReadSeeker, name string, dt lsgo.DataType, length uin
 		return attr, err
 
 	default:
-		return lsgo.ReadAttribute(r, name, dt, length, l)
+		return lsgo.ReadAttribute(r, name, dt, length, binary.LittleEndian, guidMode, l)
+	}
+}
+
+// ReadLayout decodes the LSF file in r and returns the position and value of
+// every field. The fields are the ones logged by the decoder, the positions of
+// the fields in the data sections are relative to the section
+func ReadLayout(r io.ReadSeeker) (lsgo.Layout, error) {
+	var (
+		layout lsgo.Layout
+		c      = &lsgo.LayoutLogger{}
+		opts   = lsgo.Options{Logger: c}
+	)
+
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return layout, err
+	}
+	s, err := readSections(r, opts)
+	if err != nil {
+		return layout, err
+	}
+
+	// Values are only decoded with their node, decode every value in the
+	// order of the attribute table so that value[i] belongs to attribute[i]
+	values := make([]string, 0, len(s.attributeInfo))
+	for _, ai := range s.attributeInfo {
+		_, err = s.values.Seek(int64(ai.DataOffset), io.SeekStart)
+		if err != nil {
+			return layout, err
+		}
+		na, err := ReadLSFAttribute(s.values, s.name(ai.NameIndex, ai.NameOffset), ai.TypeID, ai.Length, s.hdr.Version, uint32(s.hdr.EngineVersion), s.guidMode, opts)
+		if err != nil {
+			return layout, err
+		}
+		values = append(values, na.String())
+	}
+	fields := c.Fields()
+
+	pos := start
+	for _, f := range fields {
+		if f.Section == "header" && f.Offset+f.Size > pos {
+			pos = f.Offset + f.Size
+		}
+	}
+	headerSection := lsgo.LayoutSection{
+		Name:       "header",
+		Offset:     start,
+		SizeOnDisk: pos - start,
+		Data:       make([]byte, pos-start),
+	}
+	_, err = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return layout, err
+	}
+	_, err = io.ReadFull(r, headerSection.Data)
+	if err != nil {
+		return layout, err
+	}
+	layout.Sections = append(layout.Sections, headerSection)
+
+	secs := s.hdr.dataSections()
+	offsets := make([]int64, len(secs))
+	for i := range secs {
+		offsets[i] = pos
+		pos += int64(secs[i].diskSize())
+		if secs[i].diskSize() == 0 {
+			continue
+		}
+		_, err = r.Seek(offsets[i], io.SeekStart)
+		if err != nil {
+			return layout, err
+		}
+		err = secs[i].read(r)
+		if err != nil {
+			return layout, err
+		}
+	}
+	err = s.hdr.decompress(secs)
+	if err != nil {
+		return layout, err
+	}
+	for i, sec := range secs {
+		if sec.diskSize() == 0 {
+			continue
+		}
+		layout.Sections = append(layout.Sections, lsgo.LayoutSection{
+			Name:       sec.name,
+			Offset:     offsets[i],
+			SizeOnDisk: int64(sec.diskSize()),
+			Compressed: sec.compressed(s.hdr),
+			Data:       sec.data,
+		})
+	}
+
+	layout.Fields = s.layoutFields(fields, values)
+	order := make(map[string]int, len(layout.Sections))
+	for i, ls := range layout.Sections {
+		order[ls.Name] = i
+		if ls.Name == "header" || ls.Compressed {
+			continue
+		}
+		for f := range layout.Fields {
+			if layout.Fields[f].Section == ls.Name {
+				layout.Fields[f].Offset += ls.Offset
+			}
+		}
+	}
+	sort.SliceStable(layout.Fields, func(i, j int) bool {
+		return order[layout.Fields[i].Section] < order[layout.Fields[j].Section]
+	})
+	return layout, nil
+}
+
+// layoutFields names the fields logged by ReadLayout after the section and
+// the table entry they were read from, values are the decoded attribute values
+func (s *sections) layoutFields(logged []lsgo.LayoutField, values []string) []lsgo.LayoutField {
+	var (
+		fields = make([]lsgo.LayoutField, 0, len(logged))
+		owners = s.attributeOwners()
+		count  = make(map[string]int)
+
+		// current hash entry and string of the names section
+		hash, str int
+		// node of the current key
+		keyNode string
+	)
+	for _, f := range logged {
+		n := count[f.Section+"\x00"+f.Name]
+		count[f.Section+"\x00"+f.Name]++
+		switch f.Section {
+		case "header":
+			if f.Name == "CompressionFlags" {
+				if v, err := strconv.ParseUint(f.Value, 10, 8); err == nil {
+					f.Value = fmt.Sprintf("%#x", v)
+				}
+			}
+		case "names":
+			switch f.Name {
+			case "numStrings":
+				hash, str = n, 0
+				f.Name = fmt.Sprintf("numStrings[%d]", hash)
+			case "nameLen":
+				f.Name = fmt.Sprintf("nameLen[%d][%d]", hash, str)
+			case "name":
+				f.Name = fmt.Sprintf("name[%d][%d]", hash, str)
+				str++
+			}
+		case "long node", "short node":
+			f.Section = "nodes"
+			if f.Name == "NameHashTableIndex" {
+				f.Value = s.withName(f.Value)
+			}
+			f.Name = fmt.Sprintf("node[%d].%s", n, f.Name)
+			f.Node = s.nodePath(n)
+		case "long attribute", "short attribute":
+			f.Section = "attributes"
+			switch f.Name {
+			case "NameHashTableIndex":
+				f.Value = s.withName(f.Value)
+			case "TypeAndLength":
+				if v, err := strconv.ParseUint(f.Value, 10, 32); err == nil {
+					f.Value = fmt.Sprintf("%v, %d bytes", lsgo.DataType(v&0x3f), v>>6)
+				}
+			}
+			f.Name = fmt.Sprintf("attribute[%d].%s", n, f.Name)
+			if n < len(owners) {
+				ai := s.attributeInfo[n]
+				f.Node = s.nodePath(owners[n])
+				f.Attribute = s.name(ai.NameIndex, ai.NameOffset)
+			}
+		case "attribute":
+			n = count["values"]
+			count["values"]++
+			f.Section = "values"
+			f.Attribute = f.Name
+			f.Name = fmt.Sprintf("value[%d]", n)
+			if n < len(values) {
+				f.Value = values[n]
+				f.Node = s.nodePath(owners[n])
+			}
+		case "key":
+			f.Section = "keys"
+			switch f.Name {
+			case "NodeIndex":
+				keyNode = ""
+				if i, err := strconv.Atoi(f.Value); err == nil {
+					keyNode = s.nodePath(i)
+				}
+			case "KeyName":
+				f.Value = s.withName(f.Value)
+			}
+			f.Name = fmt.Sprintf("key[%d].%s", n, f.Name)
+			f.Node = keyNode
+		default:
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// nodePath returns the names of the node at index and its parents separated by "/"
+func (s *sections) nodePath(index int) string {
+	if index < 0 || index >= len(s.nodeInfo) {
+		return ""
+	}
+	var (
+		path []string
+		seen = make(map[int]bool)
+	)
+	for index >= 0 && index < len(s.nodeInfo) && !seen[index] {
+		seen[index] = true
+		ni := s.nodeInfo[index]
+		path = append([]string{s.name(ni.NameIndex, ni.NameOffset)}, path...)
+		index = ni.ParentIndex
+	}
+	return strings.Join(path, "/")
+}
+
+// name returns the name at index and offset of the name hash table, or "" if it does not exist
+func (s *sections) name(index, offset int) string {
+	if index < 0 || index >= len(s.names) || offset < 0 || offset >= len(s.names[index]) {
+		return ""
+	}
+	return s.names[index][offset]
+}
+
+// withName appends the name to a logged name hash table reference
+func (s *sections) withName(ref string) string {
+	var index, offset int
+	if _, err := fmt.Sscan(ref, &index, &offset); err != nil {
+		return ref
+	}
+	return fmt.Sprintf("%s (%s)", ref, s.name(index, offset))
+}
+
+// attributeOwners returns the node index of every attribute
+func (s *sections) attributeOwners() []int {
+	owners := make([]int, len(s.attributeInfo))
+	for i := range owners {
+		owners[i] = -1
+	}
+	for n, ni := range s.nodeInfo {
+		for attr := ni.FirstAttributeIndex; attr >= 0 && attr < len(owners) && owners[attr] == -1; attr = s.attributeInfo[attr].NextAttributeIndex {
+			owners[attr] = n
+		}
+	}
+	return owners
+}
+
+// VersionFor returns the LSF version written for a resource with the given metadata
+func VersionFor(m lsgo.LSMetadata) lsgo.FileVersion {
+	switch {
+	case m.Major >= 4:
+		return lsgo.MaxVersion
+	case m.Major == 3:
+		return lsgo.VerExtendedNodes
+	default:
+		return lsgo.VerChunkedCompress
+	}
+}
+
+// number of buckets in the name hash table
+const nameHashBuckets = 0x200
+
+// An Encoder writes a Resource as LSF to an output stream
+type Encoder struct {
+	// Version is the LSF version to write, if it is 0 VersionFor is used to
+	// choose the version from the metadata of the resource being encoded
+	Version lsgo.FileVersion
+
+	// CompressionFlags are the compression method and level of the
+	// sections, as made by lsgo.MakeCompressionFlags. If it is 0 the
+	// sections are not compressed. Zstd can't be written
+	CompressionFlags byte
+
+	w io.Writer
+
+	hdr        Header
+	names      [][]string
+	nameIndex  map[string]uint32
+	nodes      bytes.Buffer
+	attributes bytes.Buffer
+	values     bytes.Buffer
+	keys       bytes.Buffer
+	nodeCount  int32
+	attrCount  int32
+	guidMode   lsgo.GUIDMode
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the LSF encoding of res to the stream.
+func (e *Encoder) Encode(res *lsgo.Resource) error {
+	e.hdr = Header{Version: e.Version}
+	if e.hdr.Version == 0 {
+		e.hdr.Version = VersionFor(res.Metadata)
+	}
+	if e.hdr.Version < lsgo.VerInitial || e.hdr.Version > lsgo.MaxVersion {
+		return fmt.Errorf("LSF version %v is not supported", e.hdr.Version)
+	}
+	copy(e.hdr.Signature[:], Signature)
+	e.hdr.SetMetadata(res.Metadata)
+	if e.hdr.Version >= lsgo.VerExtendedNodes {
+		e.hdr.Extended = 1
+	}
+	e.names = make([][]string, nameHashBuckets)
+	e.nameIndex = make(map[string]uint32)
+	e.nodes.Reset()
+	e.attributes.Reset()
+	e.values.Reset()
+	e.keys.Reset()
+	e.nodeCount, e.attrCount = 0, 0
+	// The bytes of a UUID are the same in every GUIDMode, only the
+	// interpretation differs, so they are written in the mode they were read with
+	e.guidMode = res.GUIDMode
+
+	for _, region := range res.Regions {
+		_, err := e.encodeNode(region, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	var names bytes.Buffer
+	err := e.writeNames(&names)
+	if err != nil {
+		return err
+	}
+	e.hdr.StringsUncompressedSize = uint32(names.Len())
+	e.hdr.KeysUncompressedSize = uint32(e.keys.Len())
+	e.hdr.NodesUncompressedSize = uint32(e.nodes.Len())
+	e.hdr.AttributesUncompressedSize = uint32(e.attributes.Len())
+	e.hdr.ValuesUncompressedSize = uint32(e.values.Len())
+
+	// The keys are stored after the values
+	sections := []*bytes.Buffer{&names, &e.nodes, &e.attributes, &e.values, &e.keys}
+	e.hdr.CompressionFlags = e.CompressionFlags
+	if e.hdr.CompressionFlags != 0 {
+		sections, err = e.compress(sections)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = e.hdr.Write(e.w)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		_, err = section.WriteTo(e.w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compress returns sections, in the order of Header.dataSections,
+// compressed as given by e.CompressionFlags and sets their sizes on disk.
+// Empty sections are left empty, a section without a size on disk is read
+// as uncompressed
+func (e *Encoder) compress(sections []*bytes.Buffer) ([]*bytes.Buffer, error) {
+	var (
+		flags  = e.hdr.CompressionFlags
+		method = lsgo.CompressionFlagsToMethod(flags)
+		level  = lsgo.CompressionLevel(flags & 0xf0)
+	)
+	if method == lsgo.CMInvalid {
+		return nil, fmt.Errorf("invalid compression flags %#x", flags)
+	}
+	if level != lsgo.FastCompression && level != lsgo.DefaultCompression && level != lsgo.MaxCompression {
+		return nil, fmt.Errorf("invalid compression level %#x", byte(level))
+	}
+
+	var (
+		secs       = e.hdr.dataSections()
+		compressed = make([]*bytes.Buffer, len(sections))
+		sizes      = make([]uint32, len(sections))
+	)
+	for i, section := range sections {
+		compressed[i] = &bytes.Buffer{}
+		if section.Len() == 0 {
+			continue
+		}
+		err := lsgo.Compress(compressed[i], section.Bytes(), method, level, secs[i].chunked)
+		if err != nil {
+			return nil, fmt.Errorf("LSF %s: %w", secs[i].name, err)
+		}
+		sizes[i] = uint32(compressed[i].Len())
+	}
+	e.hdr.StringsSizeOnDisk = sizes[0]
+	e.hdr.NodesSizeOnDisk = sizes[1]
+	e.hdr.AttributesSizeOnDisk = sizes[2]
+	e.hdr.ValuesSizeOnDisk = sizes[3]
+	e.hdr.KeysSizeOnDisk = sizes[4]
+	return compressed, nil
+}
+
+// name returns the name hash table reference of name, adding it if needed
+func (e *Encoder) name(name string) (uint32, error) {
+	if ref, ok := e.nameIndex[name]; ok {
+		return ref, nil
+	}
+	if len(name) > 0xffff {
+		return 0, fmt.Errorf("name %.20q... is too long", name)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	bucket := h.Sum32() % nameHashBuckets
+	if len(e.names[bucket]) > 0xffff {
+		return 0, fmt.Errorf("too many names in hash bucket %d", bucket)
+	}
+	ref := bucket<<16 | uint32(len(e.names[bucket]))
+	e.names[bucket] = append(e.names[bucket], name)
+	e.nameIndex[name] = ref
+	return ref, nil
+}
+
+func (e *Encoder) writeNames(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, uint32(len(e.names)))
+	if err != nil {
+		return err
+	}
+	for _, bucket := range e.names {
+		err = binary.Write(w, binary.LittleEndian, uint16(len(bucket)))
+		if err != nil {
+			return err
+		}
+		for _, name := range bucket {
+			err = binary.Write(w, binary.LittleEndian, uint16(len(name)))
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeNode writes n and its descendants, the nodes are numbered depth first.
+// It returns the offset of the next sibling field of n in e.nodes so it can
+// be filled in once the sibling is written, or -1 for short nodes
+func (e *Encoder) encodeNode(n *lsgo.Node, parent int32) (int, error) {
+	var (
+		index = e.nodeCount
+		long  = e.hdr.Extended == 1
+	)
+	e.nodeCount++
+
+	name, err := e.name(n.Name)
+	if err != nil {
+		return -1, err
+	}
+
+	if n.Key != "" {
+		if e.hdr.Version < lsgo.VerBG3NodeKeys {
+			return -1, fmt.Errorf("node %s has a key, keys need LSF version %d", n.Name, lsgo.VerBG3NodeKeys)
+		}
+		var key uint32
+		key, err = e.name(n.Key)
+		if err != nil {
+			return -1, err
+		}
+		err = binary.Write(&e.keys, binary.LittleEndian, KeyEntry{NodeIndex: uint32(index), KeyName: key})
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	firstAttribute := int32(-1)
+	if len(n.Attributes) > 0 {
+		firstAttribute = e.attrCount
+	}
+
+	siblingOffset := -1
+	if long {
+		siblingOffset = e.nodes.Len() + 8
+		err = binary.Write(&e.nodes, binary.LittleEndian, []int32{int32(name), parent, -1, firstAttribute})
+	} else {
+		err = binary.Write(&e.nodes, binary.LittleEndian, []int32{int32(name), firstAttribute, parent})
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	for i, attr := range n.Attributes {
+		next := int32(-1)
+		if i < len(n.Attributes)-1 {
+			next = e.attrCount + 1
+		}
+		err = e.encodeAttribute(attr, index, next, long)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	prev := -1
+	for _, child := range n.Children {
+		var offset int
+		if prev != -1 {
+			binary.LittleEndian.PutUint32(e.nodes.Bytes()[prev:], uint32(e.nodeCount))
+		}
+		offset, err = e.encodeNode(child, index)
+		if err != nil {
+			return -1, err
+		}
+		prev = offset
+	}
+	return siblingOffset, nil
+}
+
+func (e *Encoder) encodeAttribute(attr lsgo.NodeAttribute, node, next int32, long bool) error {
+	offset := e.values.Len()
+	e.attrCount++
+
+	name, err := e.name(attr.Name)
+	if err != nil {
+		return err
+	}
+
+	err = WriteLSFAttribute(&e.values, attr, e.hdr.Version, uint32(e.hdr.EngineVersion), e.guidMode)
+	if err != nil {
+		return err
+	}
+	length := e.values.Len() - offset
+	if length >= 1<<26 {
+		return fmt.Errorf("attribute %s is too long", attr.Name)
+	}
+	typeAndLength := uint32(attr.Type)&0x3f | uint32(length)<<6
+
+	if long {
+		return binary.Write(&e.attributes, binary.LittleEndian, []uint32{name, typeAndLength, uint32(next), uint32(offset)})
+	}
+	return binary.Write(&e.attributes, binary.LittleEndian, []uint32{name, typeAndLength, uint32(node)})
+}
+
+// WriteLSFAttribute writes the value of attr, it is the inverse of ReadLSFAttribute
+func WriteLSFAttribute(w io.Writer, attr lsgo.NodeAttribute, version lsgo.FileVersion, engineVersion uint32, guidMode lsgo.GUIDMode) error {
+	switch attr.Type {
+	case lsgo.DTString, lsgo.DTPath, lsgo.DTFixedString, lsgo.DTLSString, lsgo.DTWString, lsgo.DTLSWString:
+		v, ok := attr.Value.(string)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return lsgo.WriteCString(w, v)
+
+	case lsgo.DTTranslatedString:
+		v, ok := attr.Value.(lsgo.TranslatedString)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return lsgo.WriteTranslatedString(w, v, binary.LittleEndian, version, engineVersion)
+
+	case lsgo.DTTranslatedFSString:
+		v, ok := attr.Value.(lsgo.TranslatedFSString)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		return lsgo.WriteTranslatedFSString(w, v, binary.LittleEndian, version)
+
+	case lsgo.DTScratchBuffer:
+		v, ok := attr.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("attribute %s: value of type %T does not match type %v", attr.Name, attr.Value, attr.Type)
+		}
+		_, err := w.Write(v)
+		return err
+
+	default:
+		return lsgo.WriteAttribute(w, attr, binary.LittleEndian, guidMode)
 	}
 }
 
 func init() {
-	lsgo.RegisterFormat("lsf", Signature, Read)
+	lsgo.Register(lsgo.Format{
+		Name:       "lsf",
+		Magic:      Signature,
+		Extensions: []string{".lsf", ".lsfx"},
+		Decode:     ReadWithOptions,
+	})
+	lsgo.RegisterEncoder("lsf", func(w io.Writer, res *lsgo.Resource) error {
+		return NewEncoder(w).Encode(res)
+	})
 }
@@ -0,0 +1,319 @@
+package lsf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+
+	"github.com/go-kit/kit/log"
+)
+
+// encode returns res encoded as uncompressed LSF
+func encode(tb testing.TB, res *lsgo.Resource) []byte {
+	tb.Helper()
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Encode(res); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func region(name string, id int32) *lsgo.Node {
+	return &lsgo.Node{
+		Name:       name,
+		RegionName: name,
+		Attributes: []lsgo.NodeAttribute{{Name: "ID", Type: lsgo.DTInt, Value: id}},
+	}
+}
+
+func TestOpenDuplicateRegions(t *testing.T) {
+	res := &lsgo.Resource{
+		Metadata: lsgo.LSMetadata{Major: 4},
+		Regions:  []*lsgo.Node{region("A", 1), region("B", 2), region("A", 3)},
+	}
+	f, err := Open(bytes.NewReader(encode(t, res)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.RegionNames(), []string{"A", "B", "A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RegionNames() = %q, want %q", got, want)
+	}
+
+	regions, err := f.Regions("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 {
+		t.Fatalf("Regions(A) returned %d regions, want 2", len(regions))
+	}
+	for i, want := range []int32{1, 3} {
+		if got := regions[i].Attributes[0].Value; got != want {
+			t.Errorf("Regions(A)[%d] has ID %v, want %d", i, got, want)
+		}
+	}
+
+	first, err := f.Region("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != regions[0] {
+		t.Errorf("Region(A) is not the first region called A")
+	}
+	if _, err = f.Region("C"); !errors.Is(err, lsgo.ErrRegionNotFound) {
+		t.Errorf("Region(C) returned %v, want ErrRegionNotFound", err)
+	}
+}
+
+func TestReadLayout(t *testing.T) {
+	root := region("Config", 7)
+	root.Children = []*lsgo.Node{{Name: "child", Parent: root, Attributes: []lsgo.NodeAttribute{{Name: "Name", Type: lsgo.DTLSString, Value: "text"}}}}
+	data := encode(t, &lsgo.Resource{Metadata: lsgo.LSMetadata{Major: 4}, Regions: []*lsgo.Node{root}})
+
+	layout, err := ReadLayout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]lsgo.LayoutField)
+	for _, f := range layout.Fields {
+		if f.Offset < 0 || f.Offset+f.Size > int64(len(data)) {
+			t.Errorf("%s %s is outside of the file at %d+%d", f.Section, f.Name, f.Offset, f.Size)
+		}
+		fields[f.Name] = f
+	}
+	if f := fields["Signature"]; f.Section != "header" || f.Offset != 0 || f.Value != Signature {
+		t.Errorf("Signature = %+v", f)
+	}
+	if f := fields["node[1].ParentIndex"]; f.Section != "nodes" || f.Value != "0" || f.Node != "Config/child" {
+		t.Errorf("node[1].ParentIndex = %+v", f)
+	}
+	f := fields["value[1]"]
+	if f.Section != "values" || f.Attribute != "Name" || f.Node != "Config/child" || f.Value != "text" {
+		t.Errorf("value[1] = %+v", f)
+	}
+	if got := string(data[f.Offset : f.Offset+f.Size]); got != "text\x00" {
+		t.Errorf("value[1] is at %q", got)
+	}
+}
+
+type nopVisitor struct{}
+
+func (nopVisitor) EnterNode(string, int) error        { return nil }
+func (nopVisitor) Attribute(lsgo.NodeAttribute) error { return nil }
+func (nopVisitor) ExitNode()                          {}
+
+func TestOpenWithOptions(t *testing.T) {
+	// 4 nodes, the longest string is a MapKey of 37 bytes with its null terminator
+	data := encode(t, fixture.Nodes(3))
+	tests := []struct {
+		name    string
+		limits  lsgo.Limits
+		wantErr bool
+	}{
+		{"none", lsgo.Limits{}, false},
+		{"nodes", lsgo.Limits{MaxNodes: 4}, false},
+		{"too many nodes", lsgo.Limits{MaxNodes: 3}, true},
+		{"string", lsgo.Limits{MaxStringLength: 37}, false},
+		{"string too long", lsgo.Limits{MaxStringLength: 36}, true},
+	}
+	for _, tt := range tests {
+		opts := lsgo.Options{Limits: tt.limits}
+		f, err := OpenWithOptions(bytes.NewReader(data), opts)
+		if err == nil {
+			// The attribute values are only read with the region
+			_, err = f.Region("Templates")
+		}
+		if tt.wantErr != errors.Is(err, lsgo.ErrLimitExceeded) || !tt.wantErr && err != nil {
+			t.Errorf("Open %s: got %v, want ErrLimitExceeded %v", tt.name, err, tt.wantErr)
+		}
+
+		err = WalkWithOptions(bytes.NewReader(data), nopVisitor{}, opts)
+		if tt.wantErr != errors.Is(err, lsgo.ErrLimitExceeded) || !tt.wantErr && err != nil {
+			t.Errorf("Walk %s: got %v, want ErrLimitExceeded %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// BenchmarkDecode decodes an LSF file with 100k nodes from memory. The
+// logged case reads every field separately from an io.ReadSeeker the way
+// all fields used to be read
+func BenchmarkDecode(b *testing.B) {
+	data := encode(b, fixture.Nodes(100000))
+	for _, bb := range []struct {
+		name   string
+		logger log.Logger
+	}{
+		{"slices", nil},
+		{"logged", log.LoggerFunc(func(...interface{}) error { return nil })},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := ReadWithOptions(bytes.NewReader(data), lsgo.Options{Logger: bb.logger})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// compress returns res encoded as LSF with its sections compressed with method
+func compress(tb testing.TB, res *lsgo.Resource, method lsgo.CompressionMethod) []byte {
+	tb.Helper()
+	buf := &bytes.Buffer{}
+	e := NewEncoder(buf)
+	e.CompressionFlags = byte(lsgo.MakeCompressionFlags(method, lsgo.DefaultCompression))
+	if err := e.Encode(res); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestEncodeCompressed checks that a resource encoded with every method and
+// level that can be written is read back the same. LZ4 is written as raw
+// blocks before VerChunkedCompress and as frames after
+func TestEncodeCompressed(t *testing.T) {
+	res := fixture.Nodes(100)
+	for _, version := range []lsgo.FileVersion{lsgo.VerInitial, lsgo.VerChunkedCompress, lsgo.MaxVersion} {
+		buf := &bytes.Buffer{}
+		plain := NewEncoder(buf)
+		plain.Version = version
+		if err := plain.Encode(res); err != nil {
+			t.Fatal(err)
+		}
+		want, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, method := range []lsgo.CompressionMethod{lsgo.CMZlib, lsgo.CMLZ4} {
+			for _, level := range []lsgo.CompressionLevel{lsgo.FastCompression, lsgo.DefaultCompression, lsgo.MaxCompression} {
+				flags := byte(lsgo.MakeCompressionFlags(method, level))
+				buf := &bytes.Buffer{}
+				e := NewEncoder(buf)
+				e.Version = version
+				e.CompressionFlags = flags
+				if err := e.Encode(res); err != nil {
+					t.Errorf("v%d %#x: Encode: %v", version, flags, err)
+					continue
+				}
+
+				var hdr Header
+				if err := hdr.Read(bytes.NewReader(buf.Bytes())); err != nil {
+					t.Fatal(err)
+				}
+				if hdr.CompressionFlags != flags || hdr.NodesSizeOnDisk == 0 || hdr.NodesSizeOnDisk >= hdr.NodesUncompressedSize {
+					t.Errorf("v%d %#x: header has flags %#x and nodes of %d bytes compressed to %d", version, flags, hdr.CompressionFlags, hdr.NodesUncompressedSize, hdr.NodesSizeOnDisk)
+				}
+
+				got, err := Read(bytes.NewReader(buf.Bytes()))
+				if err != nil {
+					t.Errorf("v%d %#x: Read: %v", version, flags, err)
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("v%d %#x: the resource read is not the one encoded", version, flags)
+				}
+			}
+		}
+	}
+
+	for _, flags := range []int{lsgo.MakeCompressionFlags(lsgo.CMZstd, lsgo.DefaultCompression), 0x24, 0x02} {
+		e := NewEncoder(&bytes.Buffer{})
+		e.CompressionFlags = byte(flags)
+		if err := e.Encode(res); err == nil {
+			t.Errorf("Encode with compression flags %#x did not fail", flags)
+		}
+	}
+}
+
+// TestDecompressError checks that an error decompressing one of several
+// sections that are decompressed concurrently is returned
+func TestDecompressError(t *testing.T) {
+	data := compress(t, fixture.Nodes(100), lsgo.CMZlib)
+
+	// Corrupt the adler-32 checksums at the end of the nodes and values
+	r := bytes.NewReader(data)
+	var hdr Header
+	if err := hdr.Read(r); err != nil {
+		t.Fatal(err)
+	}
+	pos := int64(len(data)) - int64(r.Len())
+	for i, ds := range hdr.dataSections() {
+		pos += int64(ds.diskSize())
+		if i == 1 || i == 3 {
+			data[pos-1] ^= 0xff
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := Read(bytes.NewReader(data))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		// Errors are reported in file order
+		if err == nil || !strings.HasPrefix(err.Error(), "LSF nodes:") {
+			t.Errorf("Read returned %v, want an error decompressing the nodes", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Read did not return")
+	}
+}
+
+// BenchmarkDecompress decompresses the sections of an LSF file with 100k
+// nodes concurrently, the way Read does, and one after the other
+func BenchmarkDecompress(b *testing.B) {
+	for _, method := range []lsgo.CompressionMethod{lsgo.CMZlib, lsgo.CMLZ4} {
+		var (
+			data = compress(b, fixture.Nodes(100000), method)
+			r    = bytes.NewReader(data)
+			hdr  Header
+		)
+		if err := hdr.Read(r); err != nil {
+			b.Fatal(err)
+		}
+		secs := hdr.dataSections()
+		for i := range secs {
+			if err := secs[i].read(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+		size := 0
+		for _, ds := range secs {
+			size += int(ds.uncompressedSize)
+		}
+
+		b.Run(method.String()+"/concurrent", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				err := hdr.decompress(append([]dataSection(nil), secs...))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(method.String()+"/sequential", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				for _, ds := range secs {
+					if !ds.compressed(hdr) {
+						continue
+					}
+					_, err := lsgo.DecompressBytes(ds.data, int(ds.uncompressedSize), hdr.CompressionFlags, ds.chunked)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -8,6 +8,24 @@ import (
 
 var Logger log.Logger = log.NewNopLogger()
 
+var nopLogger = log.NewNopLogger()
+
+// Discards reports whether everything logged to l is discarded, readers use
+// it to skip building log values in hot loops
+func Discards(l log.Logger) bool {
+	return l == nopLogger
+}
+
+// With is the same as log.With, except that a logger that discards
+// everything is returned as is so that decoding with logging disabled
+// does not allocate a new context for every field
+func With(l log.Logger, keyvals ...interface{}) log.Logger {
+	if l == nopLogger {
+		return l
+	}
+	return log.With(l, keyvals...)
+}
+
 // NewFilter allows filtering of l
 func NewFilter(f map[string][]string, l log.Logger) log.Logger {
 	return filter{
@@ -0,0 +1,577 @@
+package lsj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"git.narnian.us/lordwelch/lsgo"
+
+	"github.com/google/uuid"
+)
+
+// header is the header object of an LSJ document
+type header struct {
+	Version   string `json:"version"`
+	Time      uint64 `json:"time"`
+	LSLibMeta string `json:"lslib_meta,omitempty"`
+}
+
+// attribute is the object an attribute is stored as, only the fields used
+// by the type of the attribute are set
+type attribute struct {
+	Type      json.RawMessage `json:"type"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Handle    *string         `json:"handle,omitempty"`
+	Version   *uint16         `json:"version,omitempty"`
+	Arguments []argument      `json:"arguments,omitempty"`
+}
+
+type argument struct {
+	Key    string             `json:"key"`
+	String translatedFSString `json:"string"`
+	Value  string             `json:"value"`
+}
+
+type translatedFSString struct {
+	Value     string     `json:"value"`
+	Handle    string     `json:"handle"`
+	Arguments []argument `json:"arguments"`
+}
+
+// An Encoder writes a Resource as LSJ to an output stream.
+// Attributes are written as {"type": ..., "value": ...} objects and child
+// nodes are grouped by name into arrays, the same as LSLib.
+type Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+	err error
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the LSJ encoding of res to the stream.
+func (e *Encoder) Encode(res *lsgo.Resource) error {
+	e.buf.Reset()
+	e.err = nil
+
+	m := res.Metadata
+	hdr := header{
+		Version: fmt.Sprintf("%d.%d.%d.%d", m.Major, m.Minor, m.Revision, m.Build),
+		Time:    m.Timestamp,
+	}
+	if res.GUIDMode == lsgo.GUIDByteSwapped {
+		hdr.LSLibMeta = "v1,bswap_guids"
+	}
+
+	e.buf.WriteString(`{"save":{"header":`)
+	e.marshal(hdr)
+	e.buf.WriteString(`,"regions":{`)
+	for i, region := range res.Regions {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.marshal(region.RegionName)
+		e.buf.WriteByte(':')
+		e.encodeNode(region)
+	}
+	e.buf.WriteString("}}}")
+	if e.err != nil {
+		return e.err
+	}
+
+	var out bytes.Buffer
+	err := json.Indent(&out, e.buf.Bytes(), "", "\t")
+	if err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(e.w)
+	return err
+}
+
+func (e *Encoder) encodeNode(n *lsgo.Node) {
+	var (
+		names  []string
+		groups = make(map[string][]*lsgo.Node)
+		comma  bool
+	)
+	field := func(name string) {
+		if comma {
+			e.buf.WriteByte(',')
+		}
+		comma = true
+		e.marshal(name)
+		e.buf.WriteByte(':')
+	}
+
+	e.buf.WriteByte('{')
+	if n.Key != "" {
+		field("key")
+		e.marshal(n.Key)
+	}
+	for _, na := range n.Attributes {
+		field(na.Name)
+		e.encodeAttribute(na)
+	}
+
+	for _, child := range n.Children {
+		if _, ok := groups[child.Name]; !ok {
+			names = append(names, child.Name)
+		}
+		groups[child.Name] = append(groups[child.Name], child)
+	}
+	for _, name := range names {
+		field(name)
+		e.buf.WriteByte('[')
+		for i, child := range groups[name] {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.encodeNode(child)
+		}
+		e.buf.WriteByte(']')
+	}
+	e.buf.WriteByte('}')
+}
+
+func (e *Encoder) encodeAttribute(na lsgo.NodeAttribute) {
+	a := attribute{Type: e.raw(na.Type.String())}
+
+	switch v := na.Value.(type) {
+	case lsgo.TranslatedString:
+		a.Handle = &v.Handle
+		if v.Value != "" {
+			a.Value = e.raw(v.Value)
+		} else {
+			a.Version = &v.Version
+		}
+
+	case lsgo.TranslatedFSString:
+		a.Value = e.raw(v.Value)
+		a.Handle = &v.Handle
+		a.Arguments = encodeArguments(v.Arguments)
+
+	case nil:
+
+	case byte, int8, int16, uint16, int32, uint32, int64, uint64, float32, float64, bool:
+		a.Value = e.raw(v)
+
+	default:
+		a.Value = e.raw(na.String())
+	}
+	e.marshal(a)
+}
+
+func encodeArguments(args []lsgo.TranslatedFSStringArgument) []argument {
+	out := make([]argument, 0, len(args))
+	for _, arg := range args {
+		out = append(out, argument{
+			Key: arg.Key,
+			String: translatedFSString{
+				Value:     arg.String.Value,
+				Handle:    arg.String.Handle,
+				Arguments: encodeArguments(arg.String.Arguments),
+			},
+			Value: arg.Value,
+		})
+	}
+	return out
+}
+
+// raw returns the JSON encoding of v without escaping HTML characters
+func (e *Encoder) raw(v interface{}) json.RawMessage {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+func (e *Encoder) marshal(v interface{}) {
+	e.buf.Write(e.raw(v))
+}
+
+// A Decoder reads a Resource from an LSJ input stream.
+// Attribute types may be given by name or by their numeric id.
+type Decoder struct {
+	r    io.Reader
+	opts lsgo.Options
+
+	// Nodes and attributes decoded so far, checked against opts.Limits
+	nodes      int
+	attributes int
+
+	// fileMode is the GUID mode recorded in the document, guidMode is the
+	// mode UUIDs are converted to
+	fileMode lsgo.GUIDMode
+	guidMode lsgo.GUIDMode
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, lsgo.Options{})
+}
+
+// NewDecoderWithOptions returns a new decoder that reads from r using opts.
+// The whole of the stream counts as a single section of opts.Limits
+func NewDecoderWithOptions(r io.Reader, opts lsgo.Options) *Decoder {
+	return &Decoder{r: opts.Limits.Reader(r), opts: opts}
+}
+
+// Read decodes an LSJ document from r
+func Read(r io.ReadSeeker) (lsgo.Resource, error) {
+	return NewDecoder(r).Decode()
+}
+
+// ReadWithOptions decodes an LSJ document from r using opts
+func ReadWithOptions(r io.ReadSeeker, opts lsgo.Options) (lsgo.Resource, error) {
+	return NewDecoderWithOptions(r, opts).Decode()
+}
+
+// Decode reads the next LSJ document from the stream.
+func (d *Decoder) Decode() (lsgo.Resource, error) {
+	var (
+		res lsgo.Resource
+		doc struct {
+			Save *struct {
+				Header  header          `json:"header"`
+				Regions json.RawMessage `json:"regions"`
+			} `json:"save"`
+		}
+	)
+	err := json.NewDecoder(d.r).Decode(&doc)
+	if err != nil {
+		return res, err
+	}
+	if doc.Save == nil {
+		return res, fmt.Errorf("lsj: expected a save object")
+	}
+
+	res.Metadata, err = parseVersion(doc.Save.Header.Version)
+	if err != nil {
+		return res, err
+	}
+	res.Metadata.Timestamp = doc.Save.Header.Time
+	d.nodes, d.attributes = 0, 0
+	d.fileMode = lsgo.GUIDStandard
+	for _, flag := range strings.Split(doc.Save.Header.LSLibMeta, ",") {
+		if strings.TrimSpace(flag) == "bswap_guids" {
+			d.fileMode = lsgo.GUIDByteSwapped
+		}
+	}
+	d.guidMode = d.fileMode
+	if d.opts.GUIDMode != nil {
+		d.guidMode = *d.opts.GUIDMode
+	}
+	res.GUIDMode = d.guidMode
+
+	if len(doc.Save.Regions) == 0 {
+		return res, nil
+	}
+	regions, err := objectFields(doc.Save.Regions)
+	if err != nil {
+		return res, err
+	}
+	for _, f := range regions {
+		var region *lsgo.Node
+		region, err = d.decodeNode(f.name, f.value, nil)
+		if err != nil {
+			return res, err
+		}
+		region.RegionName = f.name
+		res.Regions = append(res.Regions, region)
+	}
+	return res, nil
+}
+
+func parseVersion(s string) (lsgo.LSMetadata, error) {
+	var (
+		m     lsgo.LSMetadata
+		parts = strings.Split(s, ".")
+	)
+	if s == "" {
+		return m, nil
+	}
+	if len(parts) != 4 {
+		return m, fmt.Errorf("lsj: invalid version %q", s)
+	}
+	for i, field := range []*uint32{&m.Major, &m.Minor, &m.Revision, &m.Build} {
+		v, err := strconv.ParseUint(parts[i], 10, 32)
+		if err != nil {
+			return m, fmt.Errorf("lsj: invalid version %q: %w", s, err)
+		}
+		*field = uint32(v)
+	}
+	return m, nil
+}
+
+type field struct {
+	name  string
+	value json.RawMessage
+}
+
+// objectFields returns the fields of the JSON object raw in document order
+func objectFields(raw json.RawMessage) ([]field, error) {
+	var (
+		fields []field
+		dec    = json.NewDecoder(bytes.NewReader(raw))
+	)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("lsj: expected an object, got %v", tok)
+	}
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return fields, err
+		}
+		f := field{name: tok.(string)}
+		err = dec.Decode(&f.value)
+		if err != nil {
+			return fields, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (d *Decoder) decodeNode(name string, raw json.RawMessage, parent *lsgo.Node) (*lsgo.Node, error) {
+	node := &lsgo.Node{
+		Name:   d.opts.Interner.String(name),
+		Parent: parent,
+	}
+	d.nodes++
+	err := d.opts.Limits.CheckNodes(d.nodes)
+	if err != nil {
+		return node, err
+	}
+	fields, err := objectFields(raw)
+	if err != nil {
+		return node, err
+	}
+	for _, f := range fields {
+		err = d.opts.Limits.CheckStringLength(len(f.name))
+		if err != nil {
+			return node, err
+		}
+		switch f.value[0] {
+		case '{':
+			var na lsgo.NodeAttribute
+			na, err = d.decodeAttribute(f.name, f.value)
+			if err != nil {
+				return node, err
+			}
+			node.Attributes = append(node.Attributes, na)
+
+		case '[':
+			var children []json.RawMessage
+			err = json.Unmarshal(f.value, &children)
+			if err != nil {
+				return node, err
+			}
+			for _, c := range children {
+				var child *lsgo.Node
+				child, err = d.decodeNode(f.name, c, node)
+				if err != nil {
+					return node, err
+				}
+				node.AppendChild(child)
+			}
+
+		case '"':
+			if f.name != "key" {
+				return node, fmt.Errorf("lsj: node %s: unexpected string %s", name, f.name)
+			}
+			err = json.Unmarshal(f.value, &node.Key)
+			if err != nil {
+				return node, err
+			}
+			node.Key = d.opts.Interner.String(node.Key)
+
+		default:
+			return node, fmt.Errorf("lsj: node %s: unexpected value for %s", name, f.name)
+		}
+	}
+	return node, nil
+}
+
+func parseType(raw json.RawMessage) (lsgo.DataType, error) {
+	var (
+		name string
+		id   int
+	)
+	if json.Unmarshal(raw, &name) == nil {
+		return lsgo.ParseDataType(name)
+	}
+	err := json.Unmarshal(raw, &id)
+	if err != nil {
+		return lsgo.DTNone, fmt.Errorf("lsj: invalid attribute type %s", raw)
+	}
+	if id < int(lsgo.DTNone) || id > lsgo.DTMax {
+		return lsgo.DTNone, fmt.Errorf("lsj: unknown attribute type %d", id)
+	}
+	return lsgo.DataType(id), nil
+}
+
+func (d *Decoder) decodeAttribute(name string, raw json.RawMessage) (lsgo.NodeAttribute, error) {
+	var (
+		na = lsgo.NodeAttribute{Name: d.opts.Interner.String(name)}
+		a  attribute
+	)
+	d.attributes++
+	err := d.opts.Limits.CheckAttributes(d.attributes)
+	if err != nil {
+		return na, err
+	}
+	err = json.Unmarshal(raw, &a)
+	if err != nil {
+		return na, fmt.Errorf("lsj: attribute %s: %w", name, err)
+	}
+	na.Type, err = parseType(a.Type)
+	if err != nil {
+		return na, err
+	}
+
+	var str string
+	if len(a.Value) > 0 && a.Value[0] == '"' {
+		err = json.Unmarshal(a.Value, &str)
+		if err != nil {
+			return na, fmt.Errorf("lsj: attribute %s: %w", name, err)
+		}
+	} else if len(a.Value) > 0 && string(a.Value) != "null" {
+		str = string(a.Value)
+	}
+
+	err = d.checkStrings(str, a.Handle, a.Arguments)
+	if err != nil {
+		return na, err
+	}
+
+	switch na.Type {
+	case lsgo.DTTranslatedString:
+		ts := lsgo.TranslatedString{Value: str}
+		if a.Handle != nil {
+			ts.Handle = *a.Handle
+		}
+		if a.Version != nil {
+			ts.Version = *a.Version
+		}
+		na.Value = ts
+
+	case lsgo.DTTranslatedFSString:
+		fs := lsgo.TranslatedFSString{
+			TranslatedString: lsgo.TranslatedString{Value: str},
+			Arguments:        decodeArguments(a.Arguments),
+		}
+		if a.Handle != nil {
+			fs.Handle = *a.Handle
+		}
+		na.Value = fs
+
+	default:
+		err = na.FromString(str)
+		if err != nil {
+			return na, fmt.Errorf("lsj: attribute %s: %w", name, err)
+		}
+	}
+	switch v := na.Value.(type) {
+	case uuid.UUID:
+		na.Value = lsgo.ConvertUUID(v, d.fileMode, d.guidMode)
+	case string:
+		if na.Type == lsgo.DTFixedString {
+			na.Value = d.opts.Interner.String(v)
+		}
+	}
+	return na, nil
+}
+
+// checkStrings checks the value, handle and arguments of an attribute against opts.Limits
+func (d *Decoder) checkStrings(value string, handle *string, args []argument) error {
+	err := d.opts.Limits.CheckStringLength(len(value))
+	if err != nil {
+		return err
+	}
+	if handle != nil {
+		err = d.opts.Limits.CheckStringLength(len(*handle))
+		if err != nil {
+			return err
+		}
+	}
+	for _, arg := range args {
+		for _, s := range []string{arg.Key, arg.Value, arg.String.Handle} {
+			err = d.opts.Limits.CheckStringLength(len(s))
+			if err != nil {
+				return err
+			}
+		}
+		err = d.checkStrings(arg.String.Value, nil, arg.String.Arguments)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeArguments(args []argument) []lsgo.TranslatedFSStringArgument {
+	out := make([]lsgo.TranslatedFSStringArgument, 0, len(args))
+	for _, arg := range args {
+		out = append(out, lsgo.TranslatedFSStringArgument{
+			Key: arg.Key,
+			String: lsgo.TranslatedFSString{
+				TranslatedString: lsgo.TranslatedString{
+					Value:  arg.String.Value,
+					Handle: arg.String.Handle,
+				},
+				Arguments: decodeArguments(arg.String.Arguments),
+			},
+			Value: arg.Value,
+		})
+	}
+	return out
+}
+
+// sniff recognises a JSON object whose first key is "save". Any other JSON
+// object is a likely match
+func sniff(b []byte) lsgo.Confidence {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if !bytes.HasPrefix(b, []byte("{")) {
+		return lsgo.NoMatch
+	}
+	b = bytes.TrimLeft(b[1:], " \t\r\n")
+	if !bytes.HasPrefix(b, []byte(`"save"`)) {
+		return lsgo.LikelyMatch
+	}
+	b = bytes.TrimLeft(b[len(`"save"`):], " \t\r\n")
+	if bytes.HasPrefix(b, []byte(":")) {
+		return lsgo.CertainMatch
+	}
+	return lsgo.LikelyMatch
+}
+
+func init() {
+	lsgo.Register(lsgo.Format{
+		Name:       "lsj",
+		Sniff:      sniff,
+		Extensions: []string{".lsj"},
+		Decode:     ReadWithOptions,
+		DecodeStream: func(r io.Reader, opts lsgo.Options) (lsgo.Resource, error) {
+			return NewDecoderWithOptions(r, opts).Decode()
+		},
+	})
+	lsgo.RegisterEncoder("lsj", func(w io.Writer, res *lsgo.Resource) error {
+		return NewEncoder(w).Encode(res)
+	})
+}
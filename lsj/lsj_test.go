@@ -0,0 +1,333 @@
+package lsj
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// encode returns the LSJ encoding of a resource with a root node that has a
+// FixedString, a UUID and a TranslatedString attribute and one child
+func encode(tb testing.TB) []byte {
+	tb.Helper()
+	root := &lsgo.Node{
+		Name:       "root",
+		RegionName: "Config",
+		Attributes: []lsgo.NodeAttribute{
+			{Name: "Name", Type: lsgo.DTFixedString, Value: "Fixed"},
+			{Name: "UUID", Type: lsgo.DTUUID, Value: fixture.UUID},
+			{Name: "Text", Type: lsgo.DTTranslatedString, Value: lsgo.TranslatedString{Value: "A translated string longer than a UUID", Handle: "h1"}},
+		},
+	}
+	root.AppendChild(&lsgo.Node{Name: "child"})
+	res := &lsgo.Resource{
+		Metadata: lsgo.LSMetadata{Major: 4},
+		Regions:  []*lsgo.Node{root},
+	}
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Encode(res); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeOptions(t *testing.T) {
+	// Without the trailing newline the last byte is part of the document
+	data := bytes.TrimSpace(encode(t))
+	tests := []struct {
+		name    string
+		limits  lsgo.Limits
+		wantErr bool
+	}{
+		{"none", lsgo.Limits{}, false},
+		{"nodes", lsgo.Limits{MaxNodes: 2}, false},
+		{"too many nodes", lsgo.Limits{MaxNodes: 1}, true},
+		{"attributes", lsgo.Limits{MaxAttributes: 3}, false},
+		{"too many attributes", lsgo.Limits{MaxAttributes: 2}, true},
+		{"string", lsgo.Limits{MaxStringLength: len("A translated string longer than a UUID")}, false},
+		{"string too long", lsgo.Limits{MaxStringLength: len("A translated string longer than a UUID") - 1}, true},
+		{"size", lsgo.Limits{MaxSectionSize: int64(len(data))}, false},
+		{"too big", lsgo.Limits{MaxSectionSize: int64(len(data)) - 1}, true},
+	}
+	for _, tt := range tests {
+		_, err := ReadWithOptions(bytes.NewReader(data), lsgo.Options{Limits: tt.limits})
+		if tt.wantErr != errors.Is(err, lsgo.ErrLimitExceeded) || !tt.wantErr && err != nil {
+			t.Errorf("%s: got %v, want ErrLimitExceeded %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	swapped := lsgo.GUIDByteSwapped
+	res, err := ReadWithOptions(bytes.NewReader(data), lsgo.Options{GUIDMode: &swapped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lsgo.ConvertUUID(fixture.UUID, lsgo.GUIDStandard, lsgo.GUIDByteSwapped)
+	if got := res.Regions[0].Attributes[1]; res.GUIDMode != swapped || got.Value != want {
+		t.Errorf("GUIDMode %v UUID %v, want %v %v", res.GUIDMode, got.Value, swapped, want)
+	}
+
+	in := &lsgo.Interner{}
+	for i := 0; i < 2; i++ {
+		_, err = ReadWithOptions(bytes.NewReader(data), lsgo.Options{Interner: in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Config, child, the attribute names and Fixed
+		if in.Len() != 6 {
+			t.Errorf("decode %d: the interner has %d strings, want 6", i, in.Len())
+		}
+	}
+}
+
+// document returns an LSJ document with a region root that has the
+// attribute or child nodes a
+func document(a string) []byte {
+	return []byte(`{"save":{"header":{"version":"4.0.9.0","time":0},"regions":{"root":{"a":` + a + `}}}}`)
+}
+
+// encodeAttribute returns the compact JSON of na as written by the encoder
+func encodeAttribute(tb testing.TB, na lsgo.NodeAttribute) string {
+	tb.Helper()
+	na.Name = "a"
+	res := &lsgo.Resource{Regions: []*lsgo.Node{{Name: "root", RegionName: "root", Attributes: []lsgo.NodeAttribute{na}}}}
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Encode(res); err != nil {
+		tb.Fatal(err)
+	}
+	var doc struct {
+		Save struct {
+			Regions map[string]map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		tb.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := json.Compact(out, doc.Save.Regions["root"]["a"]); err != nil {
+		tb.Fatal(err)
+	}
+	return out.String()
+}
+
+// decodeAttribute returns the attribute a of a document made by document
+func decodeAttribute(a string) (lsgo.NodeAttribute, error) {
+	res, err := Read(bytes.NewReader(document(a)))
+	if err != nil {
+		return lsgo.NodeAttribute{}, err
+	}
+	attrs := res.Regions[0].Attributes
+	if len(attrs) != 1 {
+		return lsgo.NodeAttribute{}, fmt.Errorf("decoded %d attributes, want 1", len(attrs))
+	}
+	return attrs[0], nil
+}
+
+func sameValue(a, b interface{}) bool {
+	if m, ok := a.(*lsgo.Mat); ok {
+		n, ok := b.(*lsgo.Mat)
+		return ok && mat.Equal((*mat.Dense)(m), (*mat.Dense)(n))
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// TestAttributeJSON checks the JSON of the value of every DataType both ways
+func TestAttributeJSON(t *testing.T) {
+	values := fixture.Values()
+	// LSJ does not store the versions of a TranslatedFSString
+	fs := values[lsgo.DTTranslatedFSString].(lsgo.TranslatedFSString)
+	fs.Version = 0
+	fs.Arguments[0].String.Version = 0
+	values[lsgo.DTTranslatedFSString] = fs
+
+	tests := map[lsgo.DataType]string{
+		lsgo.DTNone:               `{"type":"None"}`,
+		lsgo.DTByte:               `{"type":"uint8","value":255}`,
+		lsgo.DTShort:              `{"type":"int16","value":-32768}`,
+		lsgo.DTUShort:             `{"type":"uint16","value":65535}`,
+		lsgo.DTInt:                `{"type":"int32","value":-2147483648}`,
+		lsgo.DTUInt:               `{"type":"uint32","value":4294967295}`,
+		lsgo.DTFloat:              `{"type":"float","value":0.1}`,
+		lsgo.DTDouble:             `{"type":"double","value":0.1}`,
+		lsgo.DTIVec2:              `{"type":"ivec2","value":"1 -2"}`,
+		lsgo.DTIVec3:              `{"type":"ivec3","value":"1 -2 3"}`,
+		lsgo.DTIVec4:              `{"type":"ivec4","value":"1 -2 3 2147483647"}`,
+		lsgo.DTVec2:               `{"type":"fvec2","value":"0.5 -1"}`,
+		lsgo.DTVec3:               `{"type":"fvec3","value":"0.5 -1 0.1"}`,
+		lsgo.DTVec4:               `{"type":"fvec4","value":"0.5 -1 0.1 10000000000"}`,
+		lsgo.DTMat2:               `{"type":"mat2x2","value":"1 2 3 4"}`,
+		lsgo.DTMat3:               `{"type":"mat3x3","value":"1 0 0 0 1 0 0 0 1"}`,
+		lsgo.DTMat3x4:             `{"type":"mat3x4","value":"1 2 3 4 5 6 7 8 9 10 11 0.1"}`,
+		lsgo.DTMat4x3:             `{"type":"mat4x3","value":"1 2 3 4 5 6 7 8 9 10 11 -0.5"}`,
+		lsgo.DTMat4:               `{"type":"mat4x4","value":"1 0 0 0 0 1 0 0 0 0 1 0 0.25 0.5 0.75 1"}`,
+		lsgo.DTBool:               `{"type":"bool","value":true}`,
+		lsgo.DTString:             `{"type":"string","value":"string with spaces"}`,
+		lsgo.DTPath:               `{"type":"path","value":"Public/Shared/Assets/a.dds"}`,
+		lsgo.DTFixedString:        `{"type":"FixedString","value":"FixedString"}`,
+		lsgo.DTLSString:           `{"type":"LSString","value":"LSString"}`,
+		lsgo.DTULongLong:          `{"type":"uint64","value":18446744073709551615}`,
+		lsgo.DTScratchBuffer:      `{"type":"ScratchBuffer","value":"AAEC/w=="}`,
+		lsgo.DTLong:               `{"type":"old_int64","value":-9223372036854775808}`,
+		lsgo.DTInt8:               `{"type":"int8","value":-128}`,
+		lsgo.DTTranslatedString:   `{"type":"TranslatedString","handle":"h0123456789abcdef","version":2}`,
+		lsgo.DTWString:            `{"type":"WString","value":"WString"}`,
+		lsgo.DTLSWString:          `{"type":"LSWString","value":"LSWString"}`,
+		lsgo.DTUUID:               `{"type":"guid","value":"0d6d0e8b-6d3e-4d1b-9d3c-2a7e5c1e8f00"}`,
+		lsgo.DTInt64:              `{"type":"int64","value":9223372036854775807}`,
+		lsgo.DTTranslatedFSString: `{"type":"TranslatedFSString","value":"","handle":"h1","arguments":[{"key":"Key","string":{"value":"","handle":"h2","arguments":[]},"value":"Value"}]}`,
+	}
+	for dt := lsgo.DTNone; dt <= lsgo.DTMax; dt++ {
+		want, ok := tests[dt]
+		if !ok {
+			t.Errorf("%v: no test", dt)
+			continue
+		}
+		if got := encodeAttribute(t, lsgo.NodeAttribute{Type: dt, Value: values[dt]}); got != want {
+			t.Errorf("%v: encoded as %s, want %s", dt, got, want)
+		}
+
+		na, err := decodeAttribute(want)
+		if err != nil {
+			t.Errorf("%v: decoding %s: %v", dt, want, err)
+			continue
+		}
+		if na.Type != dt || !sameValue(na.Value, values[dt]) {
+			t.Errorf("%v: %s decoded as %v %#v, want %#v", dt, want, na.Type, na.Value, values[dt])
+		}
+	}
+}
+
+func TestTranslatedFSStringArguments(t *testing.T) {
+	type (
+		fs  = lsgo.TranslatedFSString
+		ts  = lsgo.TranslatedString
+		arg = lsgo.TranslatedFSStringArgument
+	)
+	none := []arg{}
+	tests := []struct {
+		name string
+		json string
+		want fs
+		// encoded is the JSON written for want if it is not json
+		encoded string
+	}{
+		// An empty list is only written for the arguments of an argument
+		{
+			name: "no arguments",
+			json: `{"type":"TranslatedFSString","value":"text","handle":"h1"}`,
+			want: fs{TranslatedString: ts{Value: "text", Handle: "h1"}, Arguments: none},
+		},
+		{
+			name:    "empty arguments",
+			json:    `{"type":"TranslatedFSString","value":"text","handle":"h1","arguments":[]}`,
+			want:    fs{TranslatedString: ts{Value: "text", Handle: "h1"}, Arguments: none},
+			encoded: `{"type":"TranslatedFSString","value":"text","handle":"h1"}`,
+		},
+		{
+			name:    "type id",
+			json:    `{"type":33,"value":"","handle":"h1"}`,
+			want:    fs{TranslatedString: ts{Handle: "h1"}, Arguments: none},
+			encoded: `{"type":"TranslatedFSString","value":"","handle":"h1"}`,
+		},
+		{
+			name: "two arguments",
+			json: `{"type":"TranslatedFSString","value":"[1] of [2]","handle":"h1","arguments":[` +
+				`{"key":"1","string":{"value":"one","handle":"h2","arguments":[]},"value":""},` +
+				`{"key":"2","string":{"value":"","handle":"h3","arguments":[]},"value":"two"}]}`,
+			want: fs{
+				TranslatedString: ts{Value: "[1] of [2]", Handle: "h1"},
+				Arguments: []arg{
+					{Key: "1", String: fs{TranslatedString: ts{Value: "one", Handle: "h2"}, Arguments: none}},
+					{Key: "2", String: fs{TranslatedString: ts{Handle: "h3"}, Arguments: none}, Value: "two"},
+				},
+			},
+		},
+		{
+			name: "nested",
+			json: `{"type":"TranslatedFSString","value":"","handle":"h1","arguments":[` +
+				`{"key":"outer","string":{"value":"\"<&>\" ☃","handle":"h2","arguments":[` +
+				`{"key":"inner","string":{"value":"","handle":"h3","arguments":[]},"value":"a;b"}]},"value":""}]}`,
+			want: fs{
+				TranslatedString: ts{Handle: "h1"},
+				Arguments: []arg{{
+					Key: "outer",
+					String: fs{
+						TranslatedString: ts{Value: `"<&>" ☃`, Handle: "h2"},
+						Arguments: []arg{{
+							Key:    "inner",
+							String: fs{TranslatedString: ts{Handle: "h3"}, Arguments: none},
+							Value:  "a;b",
+						}},
+					},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		na, err := decodeAttribute(tt.json)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(na.Value, tt.want) {
+			t.Errorf("%s: decoded %#v, want %#v", tt.name, na.Value, tt.want)
+		}
+
+		want := tt.json
+		if tt.encoded != "" {
+			want = tt.encoded
+		}
+		if got := encodeAttribute(t, lsgo.NodeAttribute{Type: lsgo.DTTranslatedFSString, Value: tt.want}); got != want {
+			t.Errorf("%s: encoded as %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ``},
+		{"not json", `save`},
+		{"array", `[]`},
+		{"no save", `{}`},
+		{"truncated", string(document(`{"type":"int32","value":1}`)[:60])},
+		{"short version", `{"save":{"header":{"version":"4.0"}}}`},
+		{"bad version", `{"save":{"header":{"version":"4.0.x.0"}}}`},
+		{"regions array", `{"save":{"regions":[]}}`},
+		{"null region", `{"save":{"regions":{"root":null}}}`},
+		{"node string", string(document(`"text"`))},
+		{"node number", string(document(`1`))},
+		{"child number", string(document(`[1]`))},
+		{"no type", string(document(`{}`))},
+		{"unknown type", string(document(`{"type":"int","value":1}`))},
+		{"type id too large", string(document(`{"type":34,"value":1}`))},
+		{"negative type id", string(document(`{"type":-1,"value":1}`))},
+		{"type bool", string(document(`{"type":true,"value":1}`))},
+		{"string for int", string(document(`{"type":"int32","value":"one"}`))},
+		{"out of range", string(document(`{"type":"uint8","value":256}`))},
+		{"fraction for int", string(document(`{"type":"int32","value":1.5}`))},
+		{"short vector", string(document(`{"type":"fvec3","value":"1 2"}`))},
+		{"short matrix", string(document(`{"type":"mat2x2","value":"1 2 3"}`))},
+		{"bad bool", string(document(`{"type":"bool","value":"yes"}`))},
+		{"bad guid", string(document(`{"type":"guid","value":"not a guid"}`))},
+		{"bad base64", string(document(`{"type":"ScratchBuffer","value":"!!"}`))},
+		{"handle number", string(document(`{"type":"TranslatedString","handle":1,"version":1}`))},
+		{"version too large", string(document(`{"type":"TranslatedString","handle":"h1","version":65536}`))},
+		{"arguments object", string(document(`{"type":"TranslatedFSString","value":"","handle":"h1","arguments":{}}`))},
+	}
+	for _, tt := range tests {
+		res, err := Read(bytes.NewReader([]byte(tt.data)))
+		if err == nil {
+			t.Errorf("%s: decoded %#v, want an error", tt.name, res)
+		}
+	}
+}
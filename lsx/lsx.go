@@ -0,0 +1,853 @@
+package lsx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"git.narnian.us/lordwelch/lsgo"
+
+	"github.com/google/uuid"
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	// Header is the xml declaration written by LSLib
+	Header = `<?xml version="1.0" encoding="utf-8"?>`
+
+	// bom is the UTF-8 byte order mark .NET writes before the xml declaration
+	bom = "\uFEFF"
+
+	newline = "\r\n"
+	indent  = "\t"
+)
+
+// Version is the layout of an LSX document
+type Version int
+
+const (
+	// D:OS 2 layout, attribute types are written as their numeric id
+	V3 Version = 3
+
+	// BG3 layout, attribute types are written by name and the version element
+	// has an lslib_meta attribute
+	V4 Version = 4
+)
+
+// VersionFor returns the layout LSLib uses for a resource with the given metadata
+func VersionFor(m lsgo.LSMetadata) Version {
+	if m.Major >= 4 {
+		return V4
+	}
+	return V3
+}
+
+type attr struct {
+	name, value string
+}
+
+// An Encoder writes a Resource as LSX to an output stream.
+// The output is identical to what LSLib produces: a UTF-8 BOM, CRLF line
+// endings, tab indentation and self-closing empty elements.
+type Encoder struct {
+	// Version is the layout to write, if it is 0 VersionFor is used to
+	// choose the layout from the metadata of the resource being encoded
+	Version Version
+
+	w        *bufio.Writer
+	depth    int
+	err      error
+	version  Version
+	guidMode lsgo.GUIDMode
+	resMode  lsgo.GUIDMode
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes the LSX encoding of res to the stream.
+func (e *Encoder) Encode(res *lsgo.Resource) error {
+	e.version = e.Version
+	if e.version == 0 {
+		e.version = VersionFor(res.Metadata)
+	}
+
+	e.writeString(bom + Header)
+	e.open("save")
+	version := []attr{
+		{"major", strconv.FormatUint(uint64(res.Metadata.Major), 10)},
+		{"minor", strconv.FormatUint(uint64(res.Metadata.Minor), 10)},
+		{"revision", strconv.FormatUint(uint64(res.Metadata.Revision), 10)},
+		{"build", strconv.FormatUint(uint64(res.Metadata.Build), 10)},
+	}
+	// V3 has no way to record the GUID mode, readers assume GUIDStandard
+	e.resMode = res.GUIDMode
+	e.guidMode = lsgo.GUIDStandard
+	if e.version >= V4 {
+		e.guidMode = res.GUIDMode
+		version = append(version, attr{"lslib_meta", formatMeta(e.guidMode)})
+	}
+	e.empty("version", version...)
+	for _, region := range res.Regions {
+		e.open("region", attr{"id", region.RegionName})
+		e.encodeNode(region)
+		e.close("region")
+	}
+	e.close("save")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func (e *Encoder) encodeNode(n *lsgo.Node) {
+	attrs := []attr{{"id", n.Name}}
+	if n.Key != "" {
+		attrs = append(attrs, attr{"key", n.Key})
+	}
+	if len(n.Attributes) == 0 && len(n.Children) == 0 {
+		e.empty("node", attrs...)
+		return
+	}
+	e.open("node", attrs...)
+	for _, a := range n.Attributes {
+		e.encodeAttribute(a)
+	}
+	if len(n.Children) > 0 {
+		e.open("children")
+		for _, c := range n.Children {
+			e.encodeNode(c)
+		}
+		e.close("children")
+	}
+	e.close("node")
+}
+
+func (e *Encoder) encodeAttribute(na lsgo.NodeAttribute) {
+	attrs := []attr{{"id", na.Name}, {"type", na.Type.String()}}
+	if e.version < V4 {
+		attrs[1].value = strconv.Itoa(int(na.Type))
+	}
+
+	switch v := na.Value.(type) {
+	case lsgo.TranslatedString:
+		attrs = append(attrs, attr{"handle", v.Handle})
+		if v.Value != "" {
+			attrs = append(attrs, attr{"value", v.Value})
+		} else {
+			attrs = append(attrs, attr{"version", strconv.Itoa(int(v.Version))})
+		}
+		e.empty("attribute", attrs...)
+
+	case lsgo.TranslatedFSString:
+		attrs = append(attrs, attr{"value", v.Value})
+		if len(v.Arguments) == 0 {
+			e.empty("attribute", append(attrs, translatedFSStringAttrs(v)...)...)
+			return
+		}
+		e.open("attribute", append(attrs, translatedFSStringAttrs(v)...)...)
+		e.encodeArguments(v.Arguments)
+		e.close("attribute")
+
+	case uuid.UUID:
+		v = lsgo.ConvertUUID(v, e.resMode, e.guidMode)
+		e.empty("attribute", append(attrs, attr{"value", v.String()})...)
+
+	case bool:
+		value := "False"
+		if v {
+			value = "True"
+		}
+		e.empty("attribute", append(attrs, attr{"value", value})...)
+
+	default:
+		// Replace bogus 001F characters found in certain LSF nodes
+		e.empty("attribute", append(attrs, attr{"value", strings.ReplaceAll(na.String(), "\x1f", "")})...)
+	}
+}
+
+// formatMeta returns the lslib_meta attribute of the version element
+func formatMeta(mode lsgo.GUIDMode) string {
+	meta := "v1"
+	if mode == lsgo.GUIDByteSwapped {
+		meta += ",bswap_guids"
+	}
+	return meta
+}
+
+// parseMeta returns the GUID mode recorded in the lslib_meta attribute of the version element
+func parseMeta(meta string) lsgo.GUIDMode {
+	for _, flag := range strings.Split(meta, ",") {
+		if strings.TrimSpace(flag) == "bswap_guids" {
+			return lsgo.GUIDByteSwapped
+		}
+	}
+	return lsgo.GUIDStandard
+}
+
+func translatedFSStringAttrs(fs lsgo.TranslatedFSString) []attr {
+	return []attr{
+		{"handle", fs.Handle},
+		{"arguments", strconv.Itoa(len(fs.Arguments))},
+	}
+}
+
+func (e *Encoder) encodeArguments(args []lsgo.TranslatedFSStringArgument) {
+	e.open("arguments")
+	for _, arg := range args {
+		e.open("argument", attr{"key", arg.Key}, attr{"value", arg.Value})
+		attrs := append([]attr{{"value", arg.String.Value}}, translatedFSStringAttrs(arg.String)...)
+		if len(arg.String.Arguments) == 0 {
+			e.empty("string", attrs...)
+		} else {
+			e.open("string", attrs...)
+			e.encodeArguments(arg.String.Arguments)
+			e.close("string")
+		}
+		e.close("argument")
+	}
+	e.close("arguments")
+}
+
+func (e *Encoder) open(name string, attrs ...attr) {
+	e.startLine()
+	e.writeTag(name, attrs)
+	e.writeString(">")
+	e.depth++
+}
+
+func (e *Encoder) close(name string) {
+	e.depth--
+	e.startLine()
+	e.writeString("</" + name + ">")
+}
+
+func (e *Encoder) empty(name string, attrs ...attr) {
+	e.startLine()
+	e.writeTag(name, attrs)
+	e.writeString(" />")
+}
+
+func (e *Encoder) writeTag(name string, attrs []attr) {
+	e.writeString("<" + name)
+	for _, a := range attrs {
+		e.writeString(" " + a.name + `="`)
+		e.escape(a.value)
+		e.writeString(`"`)
+	}
+}
+
+// startLine starts a new indented line, the xml declaration is always the first line
+func (e *Encoder) startLine() {
+	e.writeString(newline)
+	e.writeString(strings.Repeat(indent, e.depth))
+}
+
+func (e *Encoder) writeString(s string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(s)
+}
+
+// escape writes s escaped the same way .NET escapes attribute values
+func (e *Encoder) escape(s string) {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		var esc string
+		switch r {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&quot;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			if !isInCharacterRange(r) || (r == utf8.RuneError && width == 1) {
+				esc = "\uFFFD"
+				break
+			}
+			i += width
+			continue
+		}
+		e.writeString(s[last:i])
+		e.writeString(esc)
+		i += width
+		last = i
+	}
+	e.writeString(s[last:])
+}
+
+// isInCharacterRange reports whether r is allowed in an xml document
+func isInCharacterRange(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// A Decoder reads a Resource from an LSX input stream.
+// Both the V3 and V4 layouts are accepted, attribute types may be given by
+// name or by their numeric id.
+type Decoder struct {
+	x    *xml.Decoder
+	opts lsgo.Options
+
+	// Nodes and attributes decoded so far, checked against opts.Limits
+	nodes      int
+	attributes int
+
+	// fileMode is the GUID mode recorded in the document, guidMode is the
+	// mode UUIDs are converted to
+	fileMode lsgo.GUIDMode
+	guidMode lsgo.GUIDMode
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, lsgo.Options{})
+}
+
+// NewDecoderWithOptions returns a new decoder that reads from r using opts.
+// The whole of the stream counts as a single section of opts.Limits
+func NewDecoderWithOptions(r io.Reader, opts lsgo.Options) *Decoder {
+	return &Decoder{x: xml.NewDecoder(opts.Limits.Reader(r)), opts: opts}
+}
+
+// Read decodes an LSX document from r
+func Read(r io.ReadSeeker) (lsgo.Resource, error) {
+	return NewDecoder(r).Decode()
+}
+
+// ReadWithOptions decodes an LSX document from r using opts
+func ReadWithOptions(r io.ReadSeeker, opts lsgo.Options) (lsgo.Resource, error) {
+	return NewDecoderWithOptions(r, opts).Decode()
+}
+
+// Decode reads the next LSX document from the stream.
+func (d *Decoder) Decode() (lsgo.Resource, error) {
+	var res lsgo.Resource
+	d.nodes, d.attributes = 0, 0
+	d.fileMode, d.guidMode = lsgo.GUIDStandard, lsgo.GUIDStandard
+	if d.opts.GUIDMode != nil {
+		d.guidMode = *d.opts.GUIDMode
+	}
+	res.GUIDMode = d.guidMode
+
+	start, err := d.nextStart()
+	if err != nil {
+		return res, err
+	}
+	if start.Name.Local != "save" {
+		return res, fmt.Errorf("lsx: expected <save> root element, got <%s>", start.Name.Local)
+	}
+
+	for {
+		var tok xml.Token
+		tok, err = d.next()
+		if err != nil {
+			return res, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "version":
+				res.Metadata, err = decodeVersion(t)
+				if err != nil {
+					return res, err
+				}
+				d.fileMode = parseMeta(attrValue(t, "lslib_meta"))
+				if d.opts.GUIDMode == nil {
+					d.guidMode = d.fileMode
+				}
+				res.GUIDMode = d.guidMode
+				err = d.x.Skip()
+
+			case "region":
+				var region *lsgo.Node
+				region, err = d.decodeRegion(t)
+				if region != nil {
+					res.Regions = append(res.Regions, region)
+				}
+
+			default:
+				err = d.x.Skip()
+			}
+			if err != nil {
+				return res, err
+			}
+
+		case xml.EndElement:
+			return res, nil
+		}
+	}
+}
+
+func decodeVersion(start xml.StartElement) (lsgo.LSMetadata, error) {
+	var (
+		m   lsgo.LSMetadata
+		err error
+	)
+	for _, a := range start.Attr {
+		var field *uint32
+		switch a.Name.Local {
+		case "major":
+			field = &m.Major
+		case "minor":
+			field = &m.Minor
+		case "revision":
+			field = &m.Revision
+		case "build":
+			field = &m.Build
+		default:
+			continue
+		}
+		var v uint64
+		v, err = strconv.ParseUint(a.Value, 10, 32)
+		if err != nil {
+			return m, fmt.Errorf("lsx: invalid version %s: %w", a.Name.Local, err)
+		}
+		*field = uint32(v)
+	}
+	return m, nil
+}
+
+func (d *Decoder) decodeRegion(start xml.StartElement) (*lsgo.Node, error) {
+	var region *lsgo.Node
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return region, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "node" || region != nil {
+				err = d.x.Skip()
+				if err != nil {
+					return region, err
+				}
+				continue
+			}
+			region, err = d.decodeNode(t, nil)
+			if err != nil {
+				return region, err
+			}
+			region.RegionName = attrValue(start, "id")
+
+		case xml.EndElement:
+			return region, nil
+		}
+	}
+}
+
+func (d *Decoder) decodeNode(start xml.StartElement, parent *lsgo.Node) (*lsgo.Node, error) {
+	node := &lsgo.Node{
+		Name:   d.opts.Interner.String(attrValue(start, "id")),
+		Key:    d.opts.Interner.String(attrValue(start, "key")),
+		Parent: parent,
+	}
+	d.nodes++
+	err := d.opts.Limits.CheckNodes(d.nodes)
+	if err != nil {
+		return node, err
+	}
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return node, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "attribute":
+				var na lsgo.NodeAttribute
+				na, err = d.decodeAttribute(t)
+				if err != nil {
+					return node, err
+				}
+				node.Attributes = append(node.Attributes, na)
+
+			case "children":
+				err = d.decodeChildren(node)
+
+			default:
+				err = d.x.Skip()
+			}
+			if err != nil {
+				return node, err
+			}
+
+		case xml.EndElement:
+			return node, nil
+		}
+	}
+}
+
+func (d *Decoder) decodeChildren(parent *lsgo.Node) error {
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "node" {
+				err = d.x.Skip()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			var child *lsgo.Node
+			child, err = d.decodeNode(t, parent)
+			if err != nil {
+				return err
+			}
+			parent.AppendChild(child)
+
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func parseType(s string) (lsgo.DataType, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		if id < int(lsgo.DTNone) || id > lsgo.DTMax {
+			return lsgo.DTNone, fmt.Errorf("lsx: unknown attribute type %d", id)
+		}
+		return lsgo.DataType(id), nil
+	}
+	return lsgo.ParseDataType(s)
+}
+
+func (d *Decoder) decodeAttribute(start xml.StartElement) (lsgo.NodeAttribute, error) {
+	var (
+		na = lsgo.NodeAttribute{
+			Name: d.opts.Interner.String(attrValue(start, "id")),
+		}
+		err error
+	)
+	d.attributes++
+	err = d.opts.Limits.CheckAttributes(d.attributes)
+	if err != nil {
+		return na, err
+	}
+	na.Type, err = parseType(attrValue(start, "type"))
+	if err != nil {
+		return na, err
+	}
+
+	switch na.Type {
+	case lsgo.DTTranslatedString:
+		var ts lsgo.TranslatedString
+		ts, err = decodeTranslatedString(start)
+		na.Value = ts
+		if err != nil {
+			return na, err
+		}
+		return na, d.x.Skip()
+
+	case lsgo.DTTranslatedFSString:
+		var fs lsgo.TranslatedFSString
+		fs, err = d.decodeTranslatedFSString(start)
+		na.Value = fs
+		return na, err
+
+	case lsgo.DTVec2, lsgo.DTVec3, lsgo.DTVec4, lsgo.DTMat2, lsgo.DTMat3, lsgo.DTMat3x4, lsgo.DTMat4x3, lsgo.DTMat4:
+		if _, ok := attrLookup(start, "value"); !ok {
+			// V3 layout
+			na.Value, err = d.decodeVecElements(na.Type)
+			return na, err
+		}
+	}
+
+	err = na.FromString(attrValue(start, "value"))
+	if err != nil {
+		return na, fmt.Errorf("lsx: attribute %s: %w", na.Name, err)
+	}
+	switch v := na.Value.(type) {
+	case uuid.UUID:
+		na.Value = lsgo.ConvertUUID(v, d.fileMode, d.guidMode)
+	case string:
+		if na.Type == lsgo.DTFixedString {
+			na.Value = d.opts.Interner.String(v)
+		}
+	}
+	return na, d.x.Skip()
+}
+
+var vecNames = [...]string{"x", "y", "z", "w"}
+
+// decodeVecElements reads the V3 layout of vectors (<float3 x= y= z= />) and
+// matrices (<mat4> with a float4 element for each row)
+func (d *Decoder) decodeVecElements(dt lsgo.DataType) (interface{}, error) {
+	var (
+		rows, cols int
+		values     []float64
+		err        error
+	)
+	rows, err = dt.GetRows()
+	if err != nil {
+		return nil, err
+	}
+	cols, err = dt.GetColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	depth := 0
+	for depth >= 0 {
+		var tok xml.Token
+		tok, err = d.next()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if !strings.HasPrefix(t.Name.Local, "float") {
+				continue
+			}
+			for _, name := range vecNames[:cols] {
+				var f float64
+				f, err = strconv.ParseFloat(attrValue(t, name), 32)
+				if err != nil {
+					return nil, fmt.Errorf("lsx: invalid vector element %s: %w", name, err)
+				}
+				values = append(values, f)
+			}
+
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	if len(values) != rows*cols {
+		return nil, fmt.Errorf("lsx: %d values were expected for %v, got %d", rows*cols, dt, len(values))
+	}
+	if dt == lsgo.DTVec2 || dt == lsgo.DTVec3 || dt == lsgo.DTVec4 {
+		return lsgo.Vec(values), nil
+	}
+	return (*lsgo.Mat)(mat.NewDense(rows, cols, values)), nil
+}
+
+func decodeTranslatedString(start xml.StartElement) (lsgo.TranslatedString, error) {
+	ts := lsgo.TranslatedString{
+		Handle: attrValue(start, "handle"),
+		Value:  attrValue(start, "value"),
+	}
+	if v, ok := attrLookup(start, "version"); ok {
+		version, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return ts, fmt.Errorf("lsx: invalid TranslatedString version: %w", err)
+		}
+		ts.Version = uint16(version)
+	}
+	return ts, nil
+}
+
+// decodeTranslatedFSString reads the value, handle and arguments of start,
+// start is consumed including its end element
+func (d *Decoder) decodeTranslatedFSString(start xml.StartElement) (lsgo.TranslatedFSString, error) {
+	fs := lsgo.TranslatedFSString{
+		TranslatedString: lsgo.TranslatedString{
+			Handle: attrValue(start, "handle"),
+			Value:  attrValue(start, "value"),
+		},
+	}
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return fs, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "arguments" {
+				err = d.x.Skip()
+			} else {
+				fs.Arguments, err = d.decodeArguments()
+			}
+			if err != nil {
+				return fs, err
+			}
+
+		case xml.EndElement:
+			return fs, nil
+		}
+	}
+}
+
+func (d *Decoder) decodeArguments() ([]lsgo.TranslatedFSStringArgument, error) {
+	var args []lsgo.TranslatedFSStringArgument
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return args, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "argument" {
+				err = d.x.Skip()
+				if err != nil {
+					return args, err
+				}
+				continue
+			}
+			arg := lsgo.TranslatedFSStringArgument{
+				Key:   attrValue(t, "key"),
+				Value: attrValue(t, "value"),
+			}
+			arg.String, err = d.decodeArgument()
+			if err != nil {
+				return args, err
+			}
+			args = append(args, arg)
+
+		case xml.EndElement:
+			return args, nil
+		}
+	}
+}
+
+func (d *Decoder) decodeArgument() (lsgo.TranslatedFSString, error) {
+	var fs lsgo.TranslatedFSString
+	for {
+		tok, err := d.next()
+		if err != nil {
+			return fs, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "string" {
+				err = d.x.Skip()
+			} else {
+				fs, err = d.decodeTranslatedFSString(t)
+			}
+			if err != nil {
+				return fs, err
+			}
+
+		case xml.EndElement:
+			return fs, nil
+		}
+	}
+}
+
+// next returns the next start or end element, the values of the attributes
+// of a start element are checked against opts.Limits
+func (d *Decoder) next() (xml.Token, error) {
+	for {
+		tok, err := d.x.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, a := range t.Attr {
+				err = d.opts.Limits.CheckStringLength(len(a.Value))
+				if err != nil {
+					return nil, err
+				}
+			}
+			return tok, nil
+		case xml.EndElement:
+			return tok, nil
+		}
+	}
+}
+
+// nextStart returns the next start element
+func (d *Decoder) nextStart() (xml.StartElement, error) {
+	for {
+		tok, err := d.x.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+func attrLookup(start xml.StartElement, name string) (string, bool) {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func attrValue(start xml.StartElement, name string) string {
+	v, _ := attrLookup(start, name)
+	return v
+}
+
+// sniff recognises an XML document with a <save> root element. Any other XML
+// document is a likely match, the root may be past the bytes given
+func sniff(b []byte) lsgo.Confidence {
+	b = bytes.TrimLeft(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), " \t\r\n")
+	prologue := bytes.HasPrefix(b, []byte("<?xml"))
+	if prologue {
+		i := bytes.Index(b, []byte("?>"))
+		if i < 0 {
+			return lsgo.LikelyMatch
+		}
+		b = b[i+len("?>"):]
+	}
+	for {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if !bytes.HasPrefix(b, []byte("<!--")) {
+			break
+		}
+		i := bytes.Index(b, []byte("-->"))
+		if i < 0 {
+			return lsgo.LikelyMatch
+		}
+		b = b[i+len("-->"):]
+	}
+	if bytes.HasPrefix(b, []byte("<save")) && len(b) > len("<save") && strings.IndexByte(" \t\r\n/>", b[len("<save")]) >= 0 {
+		return lsgo.CertainMatch
+	}
+	if prologue {
+		return lsgo.LikelyMatch
+	}
+	return lsgo.NoMatch
+}
+
+func init() {
+	lsgo.Register(lsgo.Format{
+		Name:       "lsx",
+		Sniff:      sniff,
+		Extensions: []string{".lsx"},
+		Decode:     ReadWithOptions,
+		DecodeStream: func(r io.Reader, opts lsgo.Options) (lsgo.Resource, error) {
+			return NewDecoderWithOptions(r, opts).Decode()
+		},
+	})
+	lsgo.RegisterEncoder("lsx", func(w io.Writer, res *lsgo.Resource) error {
+		return NewEncoder(w).Encode(res)
+	})
+}
@@ -0,0 +1,178 @@
+package lsx
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"git.narnian.us/lordwelch/lsgo"
+	"git.narnian.us/lordwelch/lsgo/internal/fixture"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// goldenResource returns a resource with the cases LSLib writes in a
+// particular way: bools, escaped characters, invalid UTF-8, 001F characters,
+// vectors, matrices and translated strings. It must be kept the same as the
+// resource written by testdata/lslib, which produces the golden files.
+func goldenResource(major uint32, mode lsgo.GUIDMode) *lsgo.Resource {
+	root := &lsgo.Node{
+		Name:       "root",
+		RegionName: "Config",
+		Attributes: []lsgo.NodeAttribute{
+			{Name: "True", Type: lsgo.DTBool, Value: true},
+			{Name: "False", Type: lsgo.DTBool, Value: false},
+			{Name: "NotABool", Type: lsgo.DTLSString, Value: "true"},
+			{Name: "Escaped", Type: lsgo.DTLSString, Value: `a "quoted" <tag> & 'apostrophe'`},
+			{Name: "Whitespace", Type: lsgo.DTLSString, Value: "tab\tnewline\ncarriage return\r"},
+			{Name: "Invalid", Type: lsgo.DTLSString, Value: "invalid utf-8\xff"},
+			{Name: "UnitSeparator", Type: lsgo.DTFixedString, Value: "unit\x1fseparator"},
+			{Name: "Unicode", Type: lsgo.DTFixedString, Value: "Café ☃"},
+			{Name: "Int", Type: lsgo.DTInt, Value: int32(-1)},
+			{Name: "Byte", Type: lsgo.DTByte, Value: byte(255)},
+			{Name: "Float", Type: lsgo.DTFloat, Value: float32(0.1)},
+			{Name: "Double", Type: lsgo.DTDouble, Value: 0.1},
+			{Name: "Vector", Type: lsgo.DTVec3, Value: lsgo.Vec{1, -0.5, float64(float32(0.1))}},
+			{Name: "Matrix", Type: lsgo.DTMat2, Value: (*lsgo.Mat)(mat.NewDense(2, 2, []float64{1, 2, 3, 4}))},
+			{Name: "Handle", Type: lsgo.DTTranslatedString, Value: lsgo.TranslatedString{Version: 1, Handle: "h5a8f1b2cg0c1dg4e3dgb7a6g9f8e7d6c5b4a"}},
+			{Name: "Text", Type: lsgo.DTTranslatedString, Value: lsgo.TranslatedString{Value: "Some text", Handle: "ls::TranslatedStringRepository::s_HandleUnknown"}},
+			{Name: "UUID", Type: lsgo.DTUUID, Value: fixture.UUID},
+		},
+	}
+	root.Children = []*lsgo.Node{
+		{Name: "empty", Parent: root},
+		{Name: "keyed", Key: "MapKey", Parent: root, Attributes: []lsgo.NodeAttribute{
+			{Name: "MapKey", Type: lsgo.DTFixedString, Value: "key"},
+		}},
+	}
+	return &lsgo.Resource{
+		Metadata: lsgo.LSMetadata{Major: major, Minor: 1, Revision: 2, Build: 3},
+		Regions:  []*lsgo.Node{root},
+		GUIDMode: mode,
+	}
+}
+
+func TestEncoderGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		res    *lsgo.Resource
+	}{
+		{"v3.lsx", goldenResource(3, lsgo.GUIDStandard)},
+		{"v4.lsx", goldenResource(4, lsgo.GUIDStandard)},
+		{"v4_bswap.lsx", goldenResource(4, lsgo.GUIDByteSwapped)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := NewEncoder(buf).Encode(tt.res); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.golden)
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s\ngot:\n%q\nwant:\n%q", golden, buf.Bytes(), want)
+			}
+			if bytes.Contains(bytes.ReplaceAll(want, []byte("\r\n"), nil), []byte("\n")) {
+				t.Errorf("%s has a line ending that is not CRLF", golden)
+			}
+		})
+	}
+}
+
+// TestDecodeGolden checks that decoding the golden files and encoding them
+// again gives the same bytes
+func TestDecodeGolden(t *testing.T) {
+	for _, golden := range []string{"v3.lsx", "v4.lsx", "v4_bswap.lsx"} {
+		want, err := ioutil.ReadFile(filepath.Join("testdata", golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := NewDecoder(bytes.NewReader(want)).Decode()
+		if err != nil {
+			t.Fatalf("%s: %v", golden, err)
+		}
+		buf := &bytes.Buffer{}
+		if err = NewEncoder(buf).Encode(&res); err != nil {
+			t.Fatalf("%s: %v", golden, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: encoding the decoded resource differs\ngot:\n%q\nwant:\n%q", golden, buf.Bytes(), want)
+		}
+	}
+}
+
+// TestEncodeInvalidCharacter checks that characters that are not allowed in
+// xml are replaced, .NET refuses to write them at all
+func TestEncodeInvalidCharacter(t *testing.T) {
+	res := goldenResource(4, lsgo.GUIDStandard)
+	res.Regions[0].Attributes = []lsgo.NodeAttribute{
+		{Name: "Control", Type: lsgo.DTLSString, Value: "control\x01\x1b"},
+	}
+	res.Regions[0].Children = nil
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Encode(res); err != nil {
+		t.Fatal(err)
+	}
+	want := `<attribute id="Control" type="LSString" value="control` + "��" + `" />`
+	if !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("got:\n%s\nwant it to contain:\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "v4.lsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The golden resource has 3 nodes, 18 attributes and its longest
+	// string is the unknown handle
+	longest := len("ls::TranslatedStringRepository::s_HandleUnknown")
+	tests := []struct {
+		name    string
+		limits  lsgo.Limits
+		wantErr bool
+	}{
+		{"none", lsgo.Limits{}, false},
+		{"nodes", lsgo.Limits{MaxNodes: 3}, false},
+		{"too many nodes", lsgo.Limits{MaxNodes: 2}, true},
+		{"attributes", lsgo.Limits{MaxAttributes: 18}, false},
+		{"too many attributes", lsgo.Limits{MaxAttributes: 17}, true},
+		{"string", lsgo.Limits{MaxStringLength: longest}, false},
+		{"string too long", lsgo.Limits{MaxStringLength: longest - 1}, true},
+		{"size", lsgo.Limits{MaxSectionSize: int64(len(data))}, false},
+		{"too big", lsgo.Limits{MaxSectionSize: int64(len(data)) - 1}, true},
+	}
+	for _, tt := range tests {
+		_, err = ReadWithOptions(bytes.NewReader(data), lsgo.Options{Limits: tt.limits})
+		if tt.wantErr != errors.Is(err, lsgo.ErrLimitExceeded) || !tt.wantErr && err != nil {
+			t.Errorf("%s: got %v, want ErrLimitExceeded %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	swapped := lsgo.GUIDByteSwapped
+	res, err := ReadWithOptions(bytes.NewReader(data), lsgo.Options{GUIDMode: &swapped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lsgo.ConvertUUID(fixture.UUID, lsgo.GUIDStandard, lsgo.GUIDByteSwapped)
+	if got := res.Regions[0].Attributes[16]; res.GUIDMode != swapped || got.Value != want {
+		t.Errorf("GUIDMode %v UUID %v, want %v %v", res.GUIDMode, got.Value, swapped, want)
+	}
+
+	in := &lsgo.Interner{}
+	for i := 0; i < 2; i++ {
+		_, err = ReadWithOptions(bytes.NewReader(data), lsgo.Options{Interner: in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Node names, attribute names and the FixedString values
+		if in.Len() != 25 {
+			t.Errorf("decode %d: the interner has %d strings, want 25", i, in.Len())
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Writes the lsx golden files in the parent directory.
+//
+// Write, WriteNode and AsString are transcribed from LSLib's LSXWriter and
+// NodeAttribute (https://github.com/Norbyte/lslib), everything that decides
+// the bytes of the output (the xml declaration, the BOM, escaping, indenting
+// and line endings) is done by System.Xml.XmlWriter. The xml writer settings
+// are the ones LSLib uses, NewLineChars is set so the files have the Windows
+// line endings LSLib writes on any platform.
+//
+// Regenerate with: dotnet run --project lsx/testdata/lslib -- lsx/testdata
+using System;
+using System.Collections.Generic;
+using System.Globalization;
+using System.IO;
+using System.Linq;
+using System.Xml;
+
+enum DataType
+{
+    DT_Byte = 1,
+    DT_Int = 4,
+    DT_Float = 6,
+    DT_Double = 7,
+    DT_Vec3 = 12,
+    DT_Mat2 = 14,
+    DT_Bool = 19,
+    DT_FixedString = 22,
+    DT_LSString = 23,
+    DT_TranslatedString = 28,
+    DT_UUID = 31,
+}
+
+enum LSXVersion
+{
+    V3 = 3,
+    V4 = 4,
+}
+
+class TranslatedString
+{
+    public UInt16 Version;
+    public string Value;
+    public string Handle;
+}
+
+class NodeAttribute
+{
+    public DataType Type;
+    public object Value;
+
+    public NodeAttribute(DataType type, object value)
+    {
+        Type = type;
+        Value = value;
+    }
+
+    public string AsString()
+    {
+        switch (Type)
+        {
+            case DataType.DT_Vec3:
+                return String.Join(" ", (float[])Value);
+
+            case DataType.DT_Mat2:
+                // Row-major, like the vectors the rows are space separated
+                return String.Join(" ", (float[])Value);
+
+            case DataType.DT_Bool:
+                return (bool)Value ? "True" : "False";
+
+            case DataType.DT_UUID:
+                return ((Guid)Value).ToString();
+
+            default:
+                return Value.ToString();
+        }
+    }
+}
+
+class Node
+{
+    public string Name;
+    public string KeyAttribute;
+    public List<KeyValuePair<string, NodeAttribute>> Attributes = new List<KeyValuePair<string, NodeAttribute>>();
+    public Dictionary<string, List<Node>> Children = new Dictionary<string, List<Node>>();
+
+    public int ChildCount => Children.Sum(c => c.Value.Count);
+
+    public Node(string name)
+    {
+        Name = name;
+    }
+
+    public Node Attribute(string id, DataType type, object value)
+    {
+        Attributes.Add(new KeyValuePair<string, NodeAttribute>(id, new NodeAttribute(type, value)));
+        return this;
+    }
+
+    public Node Child(Node child)
+    {
+        if (!Children.TryGetValue(child.Name, out var children))
+        {
+            children = new List<Node>();
+            Children.Add(child.Name, children);
+        }
+        children.Add(child);
+        return this;
+    }
+}
+
+class Resource
+{
+    public UInt32 MajorVersion, MinorVersion, Revision, BuildNumber;
+    public bool ByteSwapGuids;
+    public Dictionary<string, Node> Regions = new Dictionary<string, Node>();
+}
+
+class LSXWriter
+{
+    private Stream stream;
+    private XmlWriter writer;
+
+    public bool PrettyPrint = true;
+    public LSXVersion Version;
+
+    public LSXWriter(Stream outputStream)
+    {
+        stream = outputStream;
+    }
+
+    public void Write(Resource rsrc)
+    {
+        var settings = new XmlWriterSettings();
+        settings.Indent = PrettyPrint;
+        settings.IndentChars = "\t";
+        settings.NewLineChars = "\r\n";
+
+        using (this.writer = XmlWriter.Create(stream, settings))
+        {
+            writer.WriteStartElement("save");
+
+            writer.WriteStartElement("version");
+            writer.WriteAttributeString("major", rsrc.MajorVersion.ToString());
+            writer.WriteAttributeString("minor", rsrc.MinorVersion.ToString());
+            writer.WriteAttributeString("revision", rsrc.Revision.ToString());
+            writer.WriteAttributeString("build", rsrc.BuildNumber.ToString());
+            if (Version >= LSXVersion.V4)
+            {
+                writer.WriteAttributeString("lslib_meta", rsrc.ByteSwapGuids ? "v1,bswap_guids" : "v1");
+            }
+            writer.WriteEndElement();
+
+            foreach (var region in rsrc.Regions)
+            {
+                writer.WriteStartElement("region");
+                writer.WriteAttributeString("id", region.Key);
+                WriteNode(region.Value);
+                writer.WriteEndElement();
+            }
+
+            writer.WriteEndElement();
+            writer.Flush();
+        }
+    }
+
+    private void WriteNode(Node node)
+    {
+        writer.WriteStartElement("node");
+        writer.WriteAttributeString("id", node.Name);
+        if (node.KeyAttribute != null)
+        {
+            writer.WriteAttributeString("key", node.KeyAttribute);
+        }
+
+        foreach (var attribute in node.Attributes)
+        {
+            writer.WriteStartElement("attribute");
+            writer.WriteAttributeString("id", attribute.Key);
+            if (Version >= LSXVersion.V4)
+            {
+                writer.WriteAttributeString("type", TypeName(attribute.Value.Type));
+            }
+            else
+            {
+                writer.WriteAttributeString("type", ((int)attribute.Value.Type).ToString());
+            }
+
+            if (attribute.Value.Type == DataType.DT_TranslatedString)
+            {
+                var str = (TranslatedString)attribute.Value.Value;
+                writer.WriteAttributeString("handle", str.Handle);
+                if (str.Value != null)
+                {
+                    writer.WriteAttributeString("value", str.Value);
+                }
+                else
+                {
+                    writer.WriteAttributeString("version", str.Version.ToString());
+                }
+            }
+            else
+            {
+                // Replace bogus 001F characters found in certain LSF nodes
+                writer.WriteAttributeString("value", attribute.Value.AsString().Replace("\x1f", ""));
+            }
+
+            writer.WriteEndElement();
+        }
+
+        if (node.ChildCount > 0)
+        {
+            writer.WriteStartElement("children");
+            foreach (var children in node.Children)
+            {
+                foreach (var child in children.Value)
+                {
+                    WriteNode(child);
+                }
+            }
+            writer.WriteEndElement();
+        }
+
+        writer.WriteEndElement();
+    }
+
+    private static string TypeName(DataType type)
+    {
+        switch (type)
+        {
+            case DataType.DT_Byte: return "uint8";
+            case DataType.DT_Int: return "int32";
+            case DataType.DT_Float: return "float";
+            case DataType.DT_Double: return "double";
+            case DataType.DT_Vec3: return "fvec3";
+            case DataType.DT_Mat2: return "mat2x2";
+            case DataType.DT_Bool: return "bool";
+            case DataType.DT_FixedString: return "FixedString";
+            case DataType.DT_LSString: return "LSString";
+            case DataType.DT_TranslatedString: return "TranslatedString";
+            case DataType.DT_UUID: return "guid";
+            default: throw new ArgumentException($"unknown type {type}");
+        }
+    }
+}
+
+static class Program
+{
+    // Resource returns the same resource as goldenResource in lsx_test.go
+    static Resource Resource(UInt32 major, bool byteSwapGuids)
+    {
+        var root = new Node("root")
+            .Attribute("True", DataType.DT_Bool, true)
+            .Attribute("False", DataType.DT_Bool, false)
+            .Attribute("NotABool", DataType.DT_LSString, "true")
+            .Attribute("Escaped", DataType.DT_LSString, "a \"quoted\" <tag> & 'apostrophe'")
+            .Attribute("Whitespace", DataType.DT_LSString, "tab\tnewline\ncarriage return\r")
+            // LSLib reads invalid UTF-8 as U+FFFD
+            .Attribute("Invalid", DataType.DT_LSString, "invalid utf-8�")
+            .Attribute("UnitSeparator", DataType.DT_FixedString, "unit\x1fseparator")
+            .Attribute("Unicode", DataType.DT_FixedString, "Café ☃")
+            .Attribute("Int", DataType.DT_Int, -1)
+            .Attribute("Byte", DataType.DT_Byte, (byte)255)
+            .Attribute("Float", DataType.DT_Float, 0.1f)
+            .Attribute("Double", DataType.DT_Double, 0.1)
+            .Attribute("Vector", DataType.DT_Vec3, new float[] { 1, -0.5f, 0.1f })
+            .Attribute("Matrix", DataType.DT_Mat2, new float[] { 1, 2, 3, 4 })
+            .Attribute("Handle", DataType.DT_TranslatedString, new TranslatedString { Version = 1, Handle = "h5a8f1b2cg0c1dg4e3dgb7a6g9f8e7d6c5b4a" })
+            .Attribute("Text", DataType.DT_TranslatedString, new TranslatedString { Value = "Some text", Handle = "ls::TranslatedStringRepository::s_HandleUnknown" })
+            .Attribute("UUID", DataType.DT_UUID, Guid.Parse("0d6d0e8b-6d3e-4d1b-9d3c-2a7e5c1e8f00"));
+
+        var keyed = new Node("keyed") { KeyAttribute = "MapKey" }
+            .Attribute("MapKey", DataType.DT_FixedString, "key");
+        root.Child(new Node("empty")).Child(keyed);
+
+        var rsrc = new Resource
+        {
+            MajorVersion = major,
+            MinorVersion = 1,
+            Revision = 2,
+            BuildNumber = 3,
+            ByteSwapGuids = byteSwapGuids,
+        };
+        rsrc.Regions.Add("Config", root);
+        return rsrc;
+    }
+
+    static void Write(string path, UInt32 major, bool byteSwapGuids)
+    {
+        using (var f = File.Create(path))
+        {
+            var writer = new LSXWriter(f);
+            writer.Version = major >= 4 ? LSXVersion.V4 : LSXVersion.V3;
+            writer.Write(Resource(major, byteSwapGuids));
+        }
+    }
+
+    static void Main(string[] args)
+    {
+        CultureInfo.DefaultThreadCurrentCulture = CultureInfo.InvariantCulture;
+        CultureInfo.CurrentCulture = CultureInfo.InvariantCulture;
+
+        var dir = args.Length > 0 ? args[0] : ".";
+        Write(Path.Combine(dir, "v3.lsx"), 3, false);
+        Write(Path.Combine(dir, "v4.lsx"), 4, false);
+        Write(Path.Combine(dir, "v4_bswap.lsx"), 4, true);
+    }
+}
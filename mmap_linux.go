@@ -0,0 +1,22 @@
+package lsgo
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the size bytes of f into memory read-only. The mapping stays
+// valid after f is closed until unmap is called
+func mapFile(f *os.File, size int64) (b []byte, unmap func() error, err error) {
+	if size == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: syscall.EFBIG}
+	}
+	b, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return b, func() error { return syscall.Munmap(b) }, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package lsgo
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the size bytes of f into memory, memory mapping is only
+// used on Linux
+func mapFile(f *os.File, size int64) (b []byte, unmap func() error, err error) {
+	b = make([]byte, size)
+	_, err = io.ReadFull(f, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, func() error { return nil }, nil
+}
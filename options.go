@@ -0,0 +1,130 @@
+package lsgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ErrLimitExceeded is returned when a resource is larger than the Limits of its decode
+var ErrLimitExceeded = errors.New("lsgo: limit exceeded")
+
+// Options control a single decode. The zero value decodes the same as Decode
+type Options struct {
+	// Logger receives the fields as they are read, the package Logger is used if it is nil
+	Logger log.Logger
+
+	Limits Limits
+
+	// GUIDMode is the mode DTUUID attributes are decoded with, if it is nil
+	// the DefaultGUIDMode of the file is used
+	GUIDMode *GUIDMode
+
+	// Strict makes inconsistencies that are otherwise logged and skipped an
+	// error, e.g. an attribute that does not use all of its bytes or a
+	// section that does not start where the previous one ended
+	Strict bool
+
+	// Filename is the name of the file being decoded, if it is known. Its
+	// extension is a hint for Decode when the content matches more than one
+	// format or none
+	Filename string
+
+	// Interner deduplicates node names, attribute names and FixedString
+	// values, it may be shared by many decodes
+	Interner *Interner
+}
+
+// With returns the logger of o with keyvals added
+func (o Options) With(keyvals ...interface{}) log.Logger {
+	l := o.Logger
+	if l == nil {
+		l = Logger
+	}
+	return With(l, keyvals...)
+}
+
+// GUIDModeFor returns the GUIDMode to use for a file with the metadata m
+func (o Options) GUIDModeFor(m LSMetadata) GUIDMode {
+	if o.GUIDMode != nil {
+		return *o.GUIDMode
+	}
+	return DefaultGUIDMode(m)
+}
+
+// Limits bound the memory used by a decode of untrusted data, a zero field is unlimited
+type Limits struct {
+	// Largest uncompressed section of an LSF file or size of an LSB, LSX or
+	// LSJ file in bytes
+	MaxSectionSize int64
+
+	// Most nodes in a resource
+	MaxNodes int
+
+	// Most attributes in a resource
+	MaxAttributes int
+
+	// Longest string or buffer in bytes
+	MaxStringLength int
+}
+
+func checkLimit(what string, n, limit int64) error {
+	if limit > 0 && n > limit {
+		return fmt.Errorf("%w: %d %s, the limit is %d", ErrLimitExceeded, n, what, limit)
+	}
+	return nil
+}
+
+// CheckSectionSize returns an error if a section of n bytes exceeds the limit
+func (l Limits) CheckSectionSize(n int64) error {
+	return checkLimit("bytes in a section", n, l.MaxSectionSize)
+}
+
+// CheckNodes returns an error if n nodes exceed the limit
+func (l Limits) CheckNodes(n int) error {
+	return checkLimit("nodes", int64(n), int64(l.MaxNodes))
+}
+
+// CheckAttributes returns an error if n attributes exceed the limit
+func (l Limits) CheckAttributes(n int) error {
+	return checkLimit("attributes", int64(n), int64(l.MaxAttributes))
+}
+
+// CheckStringLength returns an error if a string of n bytes exceeds the limit
+func (l Limits) CheckStringLength(n int) error {
+	return checkLimit("bytes in a string", int64(n), int64(l.MaxStringLength))
+}
+
+// Reader returns a reader that reads from r until more than MaxSectionSize
+// bytes have been read, then it returns ErrLimitExceeded. It is used by
+// formats that are decoded from a stream as a single section
+func (l Limits) Reader(r io.Reader) io.Reader {
+	if l.MaxSectionSize <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, l: l}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	l    Limits
+	read int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.read > lr.l.MaxSectionSize {
+		return 0, lr.l.CheckSectionSize(lr.read)
+	}
+	// One byte past the limit is read to know that it was exceeded
+	if left := lr.l.MaxSectionSize + 1 - lr.read; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.l.MaxSectionSize {
+		return n - int(lr.read-lr.l.MaxSectionSize), lr.l.CheckSectionSize(lr.read)
+	}
+	return n, err
+}
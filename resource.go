@@ -2,7 +2,10 @@ package lsgo
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+
+	"github.com/google/uuid"
 )
 
 type LSMetadata struct {
@@ -18,11 +21,57 @@ type LSMetadata struct {
 type Resource struct {
 	Metadata LSMetadata `xml:"version"`
 	Regions  []*Node    `xml:"region"`
+
+	// GUIDMode is the mode DTUUID attributes were decoded with
+	GUIDMode GUIDMode `xml:"-"`
+}
+
+// SetGUIDMode converts all DTUUID attributes in r to mode
+func (r *Resource) SetGUIDMode(mode GUIDMode) {
+	if r.GUIDMode == mode {
+		return
+	}
+	for _, region := range r.Regions {
+		region.setGUIDMode(r.GUIDMode, mode)
+	}
+	r.GUIDMode = mode
 }
 
 func (r *Resource) Read(io.Reader) {
 }
 
+// Walk calls fn for every node of r, parents before their children. Path is
+// the names of the node and its ancestors from the region root separated by
+// "/", the same as the paths of a Schema. Loc is the same with the index of
+// each node among the siblings of the same name if there is more than one,
+// e.g. "Templates/GameObjects[3]". If fn returns false the children of the
+// node are skipped
+func (r *Resource) Walk(fn func(path, loc string, n *Node) bool) {
+	walkNodes(r.Regions, "", "", fn)
+}
+
+func walkNodes(nodes []*Node, path, loc string, fn func(path, loc string, n *Node) bool) {
+	count := make(map[string]int, len(nodes))
+	for _, n := range nodes {
+		count[n.Name]++
+	}
+	seen := make(map[string]int, len(count))
+	for _, n := range nodes {
+		name := n.Name
+		if count[n.Name] > 1 {
+			name = fmt.Sprintf("%s[%d]", n.Name, seen[n.Name])
+			seen[n.Name]++
+		}
+		p, l := n.Name, name
+		if path != "" {
+			p, l = path+"/"+p, loc+"/"+l
+		}
+		if fn(p, l, n) {
+			walkNodes(n.Children, p, l, fn)
+		}
+	}
+}
+
 // public Resource()
 // {
 //     Metadata.MajorVersion = 3;
@@ -30,6 +79,7 @@ func (r *Resource) Read(io.Reader) {
 
 type Node struct {
 	Name       string          `xml:"id,attr"`
+	Key        string          `xml:"key,attr,omitempty"`
 	Parent     *Node           `xml:"-"`
 	Attributes []NodeAttribute `xml:"attribute"`
 	Children   []*Node         `xml:"children>node,omitempty"`
@@ -37,6 +87,17 @@ type Node struct {
 	RegionName string `xml:"-"`
 }
 
+func (n *Node) setGUIDMode(from, to GUIDMode) {
+	for i, attr := range n.Attributes {
+		if v, ok := attr.Value.(uuid.UUID); ok && attr.Type == DTUUID {
+			n.Attributes[i].Value = ConvertUUID(v, from, to)
+		}
+	}
+	for _, child := range n.Children {
+		child.setGUIDMode(from, to)
+	}
+}
+
 func (n *Node) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	R := xml.Name{
 		Local: "region",
@@ -0,0 +1,37 @@
+package lsgo
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNodeMarshalXML(t *testing.T) {
+	n := &Node{
+		Name:       "root",
+		RegionName: "Region",
+		Attributes: []NodeAttribute{
+			{Name: "Name", Type: DTTranslatedString, Value: TranslatedString{Version: 1, Handle: "h1"}},
+			{Name: "Position", Type: DTVec2, Value: Vec{1, 2.5}},
+			{Name: "Transform", Type: DTMat2, Value: (*Mat)(mat.NewDense(2, 2, []float64{1, 0, 0, 1}))},
+			{Name: "Count", Type: DTInt, Value: int32(3)},
+		},
+		Children: []*Node{{Name: "child"}},
+	}
+	want := `<region id="Region"><node id="root">` +
+		`<attribute id="Name" type="TranslatedString" handle="h1" version="1"></attribute>` +
+		`<attribute id="Position" type="fvec2"><float2 x="1" y="2.5"></float2></attribute>` +
+		`<attribute id="Transform" type="mat2x2"><mat2><float2 x="1" y="0"></float2><float2 x="0" y="1"></float2></mat2></attribute>` +
+		`<attribute id="Count" type="int32" value="3"></attribute>` +
+		`<children><node id="child"></node></children>` +
+		`</node></region>`
+
+	b, err := xml.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("xml.Marshal(n) =\n%s\nwant\n%s", b, want)
+	}
+}
@@ -0,0 +1,149 @@
+package lsgo
+
+import "strings"
+
+// A Schema describes the nodes seen in a set of resources. It is built by
+// adding resources with Add and can be saved and loaded as JSON
+type Schema struct {
+	// Resources is the number of resources added to the schema
+	Resources int `json:"resources"`
+
+	// Nodes are keyed by the path of the node, the names of the node and its
+	// ancestors from the region root separated by "/"
+	Nodes map[string]*NodeSchema `json:"nodes"`
+}
+
+// A NodeSchema describes every node seen at one path
+type NodeSchema struct {
+	// Count is the number of nodes seen at this path
+	Count int `json:"count"`
+
+	Attributes map[string]*AttributeSchema `json:"attributes,omitempty"`
+
+	// Children are keyed by the name of the child node
+	Children map[string]*ChildSchema `json:"children,omitempty"`
+}
+
+// An AttributeSchema describes an attribute of a node
+type AttributeSchema struct {
+	// Count is the number of nodes the attribute was seen on
+	Count int `json:"count"`
+
+	// Types is the number of times the attribute was seen with each type,
+	// there is normally only one
+	Types map[DataType]int `json:"types"`
+
+	// Max is the most times the attribute was seen on one node
+	Max int `json:"max"`
+}
+
+// A ChildSchema describes the children of a node with one name
+type ChildSchema struct {
+	// Count is the number of nodes that have at least one child with the name
+	Count int `json:"count"`
+
+	// Min and Max are the fewest and most children with the name seen on one
+	// node, Min is 0 if the child is optional
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// NewSchema returns an empty schema
+func NewSchema() *Schema {
+	return &Schema{Nodes: make(map[string]*NodeSchema)}
+}
+
+// Add adds the nodes of res to s. It is not safe to call Add concurrently
+func (s *Schema) Add(res *Resource) {
+	if s.Nodes == nil {
+		s.Nodes = make(map[string]*NodeSchema)
+	}
+	s.Resources++
+	for _, region := range res.Regions {
+		s.addNode(region.Name, region)
+	}
+}
+
+func (s *Schema) addNode(path string, n *Node) {
+	ns := s.Nodes[path]
+	if ns == nil {
+		ns = &NodeSchema{
+			Attributes: make(map[string]*AttributeSchema),
+			Children:   make(map[string]*ChildSchema),
+		}
+		s.Nodes[path] = ns
+	}
+	ns.Count++
+
+	attrs := make(map[string]int, len(n.Attributes))
+	for _, attr := range n.Attributes {
+		as := ns.Attributes[attr.Name]
+		if as == nil {
+			as = &AttributeSchema{Types: make(map[DataType]int)}
+			ns.Attributes[attr.Name] = as
+		}
+		as.Types[attr.Type]++
+		attrs[attr.Name]++
+	}
+	for name, count := range attrs {
+		as := ns.Attributes[name]
+		as.Count++
+		if count > as.Max {
+			as.Max = count
+		}
+	}
+
+	children := make(map[string]int, len(n.Children))
+	for _, child := range n.Children {
+		children[child.Name]++
+	}
+	for name, cs := range ns.Children {
+		if children[name] == 0 {
+			cs.Min = 0
+		}
+	}
+	for name, count := range children {
+		cs := ns.Children[name]
+		if cs == nil {
+			// Nodes seen before this one did not have the child
+			cs = &ChildSchema{Min: count}
+			if ns.Count > 1 {
+				cs.Min = 0
+			}
+			ns.Children[name] = cs
+		}
+		cs.Count++
+		if count < cs.Min {
+			cs.Min = count
+		}
+		if count > cs.Max {
+			cs.Max = count
+		}
+	}
+
+	for _, child := range n.Children {
+		s.addNode(path+"/"+child.Name, child)
+	}
+}
+
+// Node returns the schema of the nodes at path, the names of a node and its
+// ancestors from the region root
+func (s *Schema) Node(path ...string) (*NodeSchema, bool) {
+	ns, ok := s.Nodes[strings.Join(path, "/")]
+	return ns, ok
+}
+
+// Required reports whether the attribute name was seen on every node
+func (ns *NodeSchema) Required(name string) bool {
+	as, ok := ns.Attributes[name]
+	return ok && as.Count == ns.Count
+}
+
+// Frequency returns the fraction of nodes the attribute name was seen on
+func (ns *NodeSchema) Frequency(name string) float64 {
+	as, ok := ns.Attributes[name]
+	if !ok || ns.Count == 0 {
+		return 0
+	}
+	return float64(as.Count) / float64(ns.Count)
+}
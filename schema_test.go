@@ -0,0 +1,126 @@
+package lsgo
+
+import "testing"
+
+// templates returns a resource with a Templates region that has the given
+// children
+func templates(children ...*Node) *Resource {
+	root := &Node{Name: "Templates", RegionName: "Templates"}
+	for _, c := range children {
+		root.AppendChild(c)
+	}
+	return &Resource{Regions: []*Node{root}}
+}
+
+func gameObject(attrs ...NodeAttribute) *Node {
+	return &Node{Name: "GameObjects", Attributes: attrs}
+}
+
+var (
+	attrMapKey   = NodeAttribute{Name: "MapKey", Type: DTFixedString, Value: "key"}
+	attrName     = NodeAttribute{Name: "Name", Type: DTLSString, Value: "name"}
+	attrMapKeyLS = NodeAttribute{Name: "MapKey", Type: DTLSString, Value: "key"}
+)
+
+// testSchema infers a schema from two resources. GameObjects is always a
+// child of Templates, Tags is only in the first resource and Extra only in
+// the second. MapKey is on every GameObjects node with two types, Name is on
+// half of them and repeated on one
+func testSchema() *Schema {
+	s := NewSchema()
+	s.Add(templates(
+		gameObject(attrMapKey, attrName),
+		gameObject(attrMapKey, attrName, attrName),
+		gameObject(attrMapKey),
+		&Node{Name: "Tags"},
+	))
+	s.Add(templates(
+		gameObject(attrMapKeyLS),
+		&Node{Name: "Extra"},
+		&Node{Name: "Extra"},
+	))
+	return s
+}
+
+func TestSchemaAttributes(t *testing.T) {
+	s := testSchema()
+	if s.Resources != 2 {
+		t.Errorf("Resources = %d, want 2", s.Resources)
+	}
+	ns, ok := s.Node("Templates", "GameObjects")
+	if !ok {
+		t.Fatal("Templates/GameObjects is not in the schema")
+	}
+	if ns.Count != 4 {
+		t.Errorf("Count = %d, want 4", ns.Count)
+	}
+	tests := []struct {
+		attr      string
+		required  bool
+		frequency float64
+		max       int
+		types     map[DataType]int
+	}{
+		{"MapKey", true, 1, 1, map[DataType]int{DTFixedString: 3, DTLSString: 1}},
+		{"Name", false, 0.5, 2, map[DataType]int{DTLSString: 3}},
+		{"Missing", false, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := ns.Required(tt.attr); got != tt.required {
+			t.Errorf("Required(%s) = %v, want %v", tt.attr, got, tt.required)
+		}
+		if got := ns.Frequency(tt.attr); got != tt.frequency {
+			t.Errorf("Frequency(%s) = %v, want %v", tt.attr, got, tt.frequency)
+		}
+		as, ok := ns.Attributes[tt.attr]
+		if !ok {
+			if tt.types != nil {
+				t.Errorf("%s is not in the schema", tt.attr)
+			}
+			continue
+		}
+		if as.Max != tt.max {
+			t.Errorf("%s Max = %d, want %d", tt.attr, as.Max, tt.max)
+		}
+		if len(as.Types) != len(tt.types) {
+			t.Errorf("%s Types = %v, want %v", tt.attr, as.Types, tt.types)
+		}
+		for dt, n := range tt.types {
+			if as.Types[dt] != n {
+				t.Errorf("%s Types = %v, want %v", tt.attr, as.Types, tt.types)
+			}
+		}
+	}
+}
+
+func TestSchemaChildren(t *testing.T) {
+	s := testSchema()
+	ns, ok := s.Node("Templates")
+	if !ok {
+		t.Fatal("Templates is not in the schema")
+	}
+	tests := []struct {
+		child           string
+		count, min, max int
+	}{
+		// On both nodes
+		{"GameObjects", 2, 1, 3},
+		// On the first node and missing from the second
+		{"Tags", 1, 0, 1},
+		// Missing from the first node and on the second
+		{"Extra", 1, 0, 2},
+	}
+	for _, tt := range tests {
+		cs, ok := ns.Children[tt.child]
+		if !ok {
+			t.Errorf("%s is not a child of Templates", tt.child)
+			continue
+		}
+		if cs.Count != tt.count || cs.Min != tt.min || cs.Max != tt.max {
+			t.Errorf("%s = %+v, want Count %d Min %d Max %d", tt.child, *cs, tt.count, tt.min, tt.max)
+		}
+	}
+	if len(ns.Children) != len(tests) {
+		t.Errorf("Templates has %d children, want %d", len(ns.Children), len(tests))
+	}
+}
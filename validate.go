@@ -0,0 +1,117 @@
+package lsgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownAttribute = errors.New("unknown attribute")
+	ErrAttributeType    = errors.New("wrong attribute type")
+	ErrMissingAttribute = errors.New("missing required attribute")
+	ErrUnexpectedChild  = errors.New("unexpected child node")
+)
+
+// A ValidationError is a difference between a node and its schema
+type ValidationError struct {
+	// Path locates the node, it is the same as the path of the schema with
+	// the index of the node among siblings of the same name if there is more
+	// than one, e.g. "Templates/GameObjects[3]"
+	Path string
+
+	Node *Node
+
+	// Attribute is the name of the attribute the error is about, if any
+	Attribute string
+
+	// Err is one of ErrUnknownAttribute, ErrAttributeType,
+	// ErrMissingAttribute and ErrUnexpectedChild
+	Err error
+
+	// Detail describes the error, e.g. the expected type
+	Detail string
+}
+
+func (e ValidationError) Error() string {
+	s := e.Path + ": " + e.Err.Error()
+	if e.Attribute != "" {
+		s += " " + e.Attribute
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks every node of res against s. It reports attributes that are
+// not in the schema or have a different type, required attributes that are
+// missing and nodes that are not in the schema. The children of a node that
+// is not in the schema are not checked
+func Validate(res *Resource, s *Schema) []ValidationError {
+	var errs []ValidationError
+	res.Walk(func(path, loc string, n *Node) bool {
+		ns, ok := s.Nodes[path]
+		if i := strings.LastIndexByte(path, '/'); i < 0 {
+			if !ok {
+				errs = append(errs, ValidationError{Path: loc, Node: n, Err: ErrUnexpectedChild, Detail: "region is not in the schema"})
+				return false
+			}
+		} else if _, allowed := s.Nodes[path[:i]].Children[n.Name]; !ok || !allowed {
+			errs = append(errs, ValidationError{Path: loc, Node: n, Err: ErrUnexpectedChild, Detail: fmt.Sprintf("%s is not a child of %s", n.Name, path[:i])})
+			return false
+		}
+		errs = validateAttributes(errs, loc, n, ns)
+		return true
+	})
+	return errs
+}
+
+func validateAttributes(errs []ValidationError, loc string, n *Node, ns *NodeSchema) []ValidationError {
+	seen := make(map[string]bool, len(n.Attributes))
+	for _, attr := range n.Attributes {
+		seen[attr.Name] = true
+		as, ok := ns.Attributes[attr.Name]
+		if !ok {
+			errs = append(errs, ValidationError{Path: loc, Node: n, Attribute: attr.Name, Err: ErrUnknownAttribute})
+			continue
+		}
+		if _, ok = as.Types[attr.Type]; !ok {
+			errs = append(errs, ValidationError{
+				Path:      loc,
+				Node:      n,
+				Attribute: attr.Name,
+				Err:       ErrAttributeType,
+				Detail:    fmt.Sprintf("%v, expected %s", attr.Type, typeNames(as.Types)),
+			})
+		}
+	}
+
+	// Sorted so that the errors are in the same order every time
+	var missing []string
+	for name := range ns.Attributes {
+		if !seen[name] && ns.Required(name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		errs = append(errs, ValidationError{Path: loc, Node: n, Attribute: name, Err: ErrMissingAttribute})
+	}
+	return errs
+}
+
+// typeNames returns the names of types sorted and separated by " or "
+func typeNames(types map[DataType]int) string {
+	names := make([]string, 0, len(types))
+	for dt := range types {
+		names = append(names, dt.String())
+	}
+	sort.Strings(names)
+	return strings.Join(names, " or ")
+}
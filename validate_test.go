@@ -0,0 +1,90 @@
+package lsgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s := testSchema()
+	tests := []struct {
+		name  string
+		res   *Resource
+		loc   string
+		attr  string
+		err   error
+		nerrs int
+	}{
+		{
+			name: "valid",
+			res:  templates(gameObject(attrMapKey), gameObject(attrMapKeyLS, attrName)),
+		},
+		{
+			name: "unknown attribute",
+			res: templates(
+				gameObject(attrMapKey), gameObject(attrMapKey), gameObject(attrMapKey),
+				gameObject(attrMapKey, NodeAttribute{Name: "Level", Type: DTInt, Value: int32(1)}),
+			),
+			loc:   "Templates/GameObjects[3]",
+			attr:  "Level",
+			err:   ErrUnknownAttribute,
+			nerrs: 1,
+		},
+		{
+			name:  "attribute type",
+			res:   templates(gameObject(NodeAttribute{Name: "MapKey", Type: DTInt, Value: int32(1)})),
+			loc:   "Templates/GameObjects",
+			attr:  "MapKey",
+			err:   ErrAttributeType,
+			nerrs: 1,
+		},
+		{
+			name:  "missing attribute",
+			res:   templates(gameObject(attrMapKey), gameObject(attrName)),
+			loc:   "Templates/GameObjects[1]",
+			attr:  "MapKey",
+			err:   ErrMissingAttribute,
+			nerrs: 1,
+		},
+		{
+			// The children of an unexpected node are not checked
+			name:  "unexpected child",
+			res:   templates(&Node{Name: "Bogus", Children: []*Node{gameObject()}}),
+			loc:   "Templates/Bogus",
+			err:   ErrUnexpectedChild,
+			nerrs: 1,
+		},
+		{
+			name:  "unexpected region",
+			res:   &Resource{Regions: []*Node{{Name: "Config", RegionName: "Config"}}},
+			loc:   "Config",
+			err:   ErrUnexpectedChild,
+			nerrs: 1,
+		},
+	}
+	for _, tt := range tests {
+		errs := Validate(tt.res, s)
+		if len(errs) != tt.nerrs {
+			t.Errorf("%s: got %d errors %v, want %d", tt.name, len(errs), errs, tt.nerrs)
+			continue
+		}
+		if tt.nerrs == 0 {
+			continue
+		}
+		e := errs[0]
+		if e.Path != tt.loc || e.Attribute != tt.attr || !errors.Is(e, tt.err) {
+			t.Errorf("%s: got %q at %s attribute %q, want %q at %s attribute %q", tt.name, e.Err, e.Path, e.Attribute, tt.err, tt.loc, tt.attr)
+		}
+	}
+}
+
+func TestValidationErrorString(t *testing.T) {
+	errs := Validate(templates(gameObject(NodeAttribute{Name: "MapKey", Type: DTInt, Value: int32(1)})), testSchema())
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(errs))
+	}
+	want := "Templates/GameObjects: wrong attribute type MapKey: int32, expected FixedString or LSString"
+	if got := errs[0].Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}